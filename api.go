@@ -0,0 +1,354 @@
+package telemock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// apiMethod serves one Bot API method; the result is wrapped in {"ok":true,"result":...}.
+type apiMethod func(b *Bot, r *http.Request) (any, error)
+
+// apiMethods lists the Bot API methods telemock understands, keyed by lowercase name
+// because Telegram matches method names case-insensitively.
+var apiMethods = map[string]apiMethod{
+	"getme": func(b *Bot, r *http.Request) (any, error) {
+		return b.GetMe(r.Context())
+	},
+	"getupdates": func(b *Bot, r *http.Request) (any, error) {
+		var p GetUpdatesParams
+		if err := decodeParams(r, &p); err != nil {
+			return nil, err
+		}
+		return b.getUpdates(r.Context(), &p)
+	},
+	"sendmessage": func(b *Bot, r *http.Request) (any, error) {
+		var p SendMessageParams
+		if err := decodeParams(r, &p); err != nil {
+			return nil, err
+		}
+		return b.SendMessage(r.Context(), &p)
+	},
+	"sendphoto": func(b *Bot, r *http.Request) (any, error) {
+		var p SendPhotoParams
+		if err := decodeParams(r, &p); err != nil {
+			return nil, err
+		}
+		return b.SendPhoto(r.Context(), &p)
+	},
+	"senddocument": func(b *Bot, r *http.Request) (any, error) {
+		var p SendDocumentParams
+		if err := decodeParams(r, &p); err != nil {
+			return nil, err
+		}
+		return b.SendDocument(r.Context(), &p)
+	},
+	"sendvideo": func(b *Bot, r *http.Request) (any, error) {
+		var p SendVideoParams
+		if err := decodeParams(r, &p); err != nil {
+			return nil, err
+		}
+		return b.SendVideo(r.Context(), &p)
+	},
+	"sendaudio": func(b *Bot, r *http.Request) (any, error) {
+		var p SendAudioParams
+		if err := decodeParams(r, &p); err != nil {
+			return nil, err
+		}
+		return b.SendAudio(r.Context(), &p)
+	},
+	"sendvoice": func(b *Bot, r *http.Request) (any, error) {
+		var p SendVoiceParams
+		if err := decodeParams(r, &p); err != nil {
+			return nil, err
+		}
+		return b.SendVoice(r.Context(), &p)
+	},
+	"sendanimation": func(b *Bot, r *http.Request) (any, error) {
+		var p SendAnimationParams
+		if err := decodeParams(r, &p); err != nil {
+			return nil, err
+		}
+		return b.SendAnimation(r.Context(), &p)
+	},
+	"getfile": func(b *Bot, r *http.Request) (any, error) {
+		var p GetFileParams
+		if err := decodeParams(r, &p); err != nil {
+			return nil, err
+		}
+		return b.GetFile(r.Context(), &p)
+	},
+	"editmessagetext": func(b *Bot, r *http.Request) (any, error) {
+		var p EditMessageTextParams
+		if err := decodeParams(r, &p); err != nil {
+			return nil, err
+		}
+		return b.EditMessageText(r.Context(), &p)
+	},
+	"editmessagereplymarkup": func(b *Bot, r *http.Request) (any, error) {
+		var p EditMessageReplyMarkupParams
+		if err := decodeParams(r, &p); err != nil {
+			return nil, err
+		}
+		return b.EditMessageReplyMarkup(r.Context(), &p)
+	},
+	"editmessagecaption": func(b *Bot, r *http.Request) (any, error) {
+		var p EditMessageCaptionParams
+		if err := decodeParams(r, &p); err != nil {
+			return nil, err
+		}
+		return b.EditMessageCaption(r.Context(), &p)
+	},
+	"deletemessage": func(b *Bot, r *http.Request) (any, error) {
+		var p DeleteMessageParams
+		if err := decodeParams(r, &p); err != nil {
+			return nil, err
+		}
+		return true, b.DeleteMessage(r.Context(), &p)
+	},
+	"deletemessages": func(b *Bot, r *http.Request) (any, error) {
+		var p DeleteMessagesParams
+		if err := decodeParams(r, &p); err != nil {
+			return nil, err
+		}
+		return true, b.DeleteMessages(r.Context(), &p)
+	},
+	"answercallbackquery": func(b *Bot, r *http.Request) (any, error) {
+		var p AnswerCallbackQueryParams
+		if err := decodeParams(r, &p); err != nil {
+			return nil, err
+		}
+		return true, b.AnswerCallbackQuery(r.Context(), &p)
+	},
+	"getchatmember": func(b *Bot, r *http.Request) (any, error) {
+		var p GetChatMemberParams
+		if err := decodeParams(r, &p); err != nil {
+			return nil, err
+		}
+		return b.GetChatMember(r.Context(), &p)
+	},
+	"getchatadministrators": func(b *Bot, r *http.Request) (any, error) {
+		var p GetChatAdministratorsParams
+		if err := decodeParams(r, &p); err != nil {
+			return nil, err
+		}
+		return b.GetChatAdministrators(r.Context(), &p)
+	},
+	"getchatmembercount": func(b *Bot, r *http.Request) (any, error) {
+		var p GetChatMemberCountParams
+		if err := decodeParams(r, &p); err != nil {
+			return nil, err
+		}
+		return b.GetChatMemberCount(r.Context(), &p)
+	},
+	"deletewebhook": func(b *Bot, r *http.Request) (any, error) {
+		return true, nil
+	},
+}
+
+type apiResponse struct {
+	Ok          bool   `json:"ok"`
+	Result      any    `json:"result,omitempty"`
+	ErrorCode   int    `json:"error_code,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// serveHTTP routes Bot API calls (/bot<token>/<method>), file downloads
+// (/file/bot<token>/<path>) and hands everything else to the websocket endpoint.
+func (b *Bot) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if rest, ok := strings.CutPrefix(r.URL.Path, "/file/bot"); ok {
+		token, filePath, _ := strings.Cut(rest, "/")
+		b.serveFile(w, r, token, filePath)
+		return
+	}
+	rest, ok := strings.CutPrefix(r.URL.Path, "/bot")
+	if !ok || !strings.Contains(rest, "/") {
+		b.handleWS(w, r)
+		return
+	}
+	token, method, _ := strings.Cut(rest, "/")
+	b.handleAPI(w, r, token, method)
+}
+
+func (b *Bot) handleAPI(w http.ResponseWriter, r *http.Request, token, method string) {
+	var (
+		result any
+		err    error
+	)
+	if token != b.token {
+		err = errUnauthorized
+	} else if fn, ok := apiMethods[strings.ToLower(method)]; ok {
+		result, err = fn(b, r)
+	} else {
+		err = errNotFound
+	}
+
+	resp := apiResponse{Ok: err == nil, Result: result}
+	status := http.StatusOK
+	if err != nil {
+		var apiErr *Error
+		if !errors.As(err, &apiErr) {
+			apiErr = errBadRequest(err.Error())
+		}
+		resp = apiResponse{ErrorCode: apiErr.ErrorCode, Description: apiErr.Description}
+		status = apiErr.ErrorCode
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// decodeParams fills dst from a JSON body, a form or a multipart form, the
+// three encodings the Bot API accepts. Form values are strings, so they are
+// converted to JSON according to the kind of the destination field.
+func decodeParams(r *http.Request, dst any) error {
+	raw := make(map[string]json.RawMessage)
+	t := reflect.TypeOf(dst).Elem()
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "application/json") {
+		if err := json.NewDecoder(r.Body).Decode(&raw); err != nil && !errors.Is(err, io.EOF) {
+			return errBadRequest("can't parse JSON: " + err.Error())
+		}
+	} else {
+		if err := r.ParseMultipartForm(32 << 20); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			return errBadRequest("can't parse request: " + err.Error())
+		}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+			if name == "" || name == "-" || !r.Form.Has(name) {
+				continue
+			}
+			v := r.Form.Get(name)
+			if f.Type.Kind() != reflect.String && json.Valid([]byte(v)) {
+				raw[name] = json.RawMessage(v)
+			} else {
+				raw[name], _ = json.Marshal(v)
+			}
+		}
+	}
+
+	// reply_markup is an interface; its concrete type depends on the content
+	markups := make(map[int]ReplyMarkup)
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if t.Field(i).Type != replyMarkupType || raw[name] == nil {
+			continue
+		}
+		if string(raw[name]) != "null" {
+			markup, err := parseReplyMarkup(raw[name])
+			if err != nil {
+				return err
+			}
+			markups[i] = markup
+		}
+		delete(raw, name)
+	}
+
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, dst); err != nil {
+		return errBadRequest("can't parse parameters: " + err.Error())
+	}
+	v := reflect.ValueOf(dst).Elem()
+	for i, markup := range markups {
+		v.Field(i).Set(reflect.ValueOf(&markup).Elem())
+	}
+	return attachFiles(r, dst)
+}
+
+var inputFileType = reflect.TypeOf(InputFile{})
+
+// attachFiles fills the InputFile fields of dst with uploaded multipart parts:
+// the part named like the parameter, or the one its attach://<name> points at
+func attachFiles(r *http.Request, dst any) error {
+	if r.MultipartForm == nil {
+		return nil
+	}
+	v := reflect.ValueOf(dst).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).Type != inputFileType {
+			continue
+		}
+		in := v.Field(i).Addr().Interface().(*InputFile)
+		part := in.attach
+		if part == "" {
+			part, _, _ = strings.Cut(v.Type().Field(i).Tag.Get("json"), ",")
+		}
+		headers := r.MultipartForm.File[part]
+		if len(headers) == 0 {
+			if in.attach != "" {
+				return errBadRequest("file " + in.attach + " not found in the request")
+			}
+			continue
+		}
+		f, err := headers[0].Open()
+		if err != nil {
+			return errBadRequest("can't read file: " + err.Error())
+		}
+		data, err := io.ReadAll(io.LimitReader(f, maxUploadSize+1))
+		f.Close()
+		if err != nil {
+			return errBadRequest("can't read file: " + err.Error())
+		}
+		in.File = NameReader(bytes.NewReader(data), headers[0].Filename)
+	}
+	return nil
+}
+
+// getUpdates implements getUpdates long polling on top of the updates channel.
+// Returned updates stay pending until a later call confirms them with a higher offset.
+func (b *Bot) getUpdates(ctx context.Context, p *GetUpdatesParams) ([]Update, error) {
+	b.pollMu.Lock()
+	defer b.pollMu.Unlock()
+
+	if p.Offset != 0 {
+		i := 0
+		for i < len(b.polled) && b.polled[i].UpdateID < p.Offset {
+			i++
+		}
+		b.polled = b.polled[i:]
+	}
+	limit := p.Limit
+	if limit <= 0 || limit > 100 {
+		limit = 100
+	}
+
+drain:
+	for len(b.polled) < limit {
+		select {
+		case u, ok := <-b.updates:
+			if !ok {
+				break drain
+			}
+			b.polled = append(b.polled, u)
+		default:
+			break drain
+		}
+	}
+
+	if len(b.polled) == 0 && p.Timeout > 0 {
+		timer := b.clock.NewTimer(time.Duration(p.Timeout) * time.Second)
+		defer timer.Stop()
+		select {
+		case u, ok := <-b.updates:
+			if ok {
+				b.polled = append(b.polled, u)
+			}
+		case <-timer.C():
+		case <-ctx.Done():
+		case <-b.done:
+		}
+	}
+
+	n := min(limit, len(b.polled))
+	return append([]Update{}, b.polled[:n]...), nil
+}
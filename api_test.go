@@ -0,0 +1,136 @@
+package telemock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+// callAPI posts a JSON body to a Bot API method and decodes the envelope
+func callAPI(t *testing.T, bot *Bot, token, method, body string) (int, apiResponse, json.RawMessage) {
+	t.Helper()
+	resp, err := http.Post(bot.APIURL()+"/bot"+token+"/"+method, "application/json", strings.NewReader(body))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var env struct {
+		apiResponse
+		Result json.RawMessage `json:"result"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&env))
+	return resp.StatusCode, env.apiResponse, env.Result
+}
+
+func TestAPI_GetMeAndErrors(t *testing.T) {
+	t.Parallel()
+	bot := newTestBot(t)
+	defer bot.Close(context.Background())
+
+	status, env, result := callAPI(t, bot, "token", "getMe", "")
+	require.Equal(t, http.StatusOK, status)
+	require.True(t, env.Ok)
+	var me User
+	require.NoError(t, json.Unmarshal(result, &me))
+	require.True(t, me.IsBot)
+	require.Equal(t, "telemock_bot", me.Username)
+
+	status, env, _ = callAPI(t, bot, "wrong", "getMe", "")
+	require.Equal(t, http.StatusUnauthorized, status)
+	require.False(t, env.Ok)
+	require.Equal(t, "Unauthorized", env.Description)
+
+	status, env, _ = callAPI(t, bot, "token", "noSuchMethod", "")
+	require.Equal(t, http.StatusNotFound, status)
+	require.Equal(t, 404, env.ErrorCode)
+
+	status, env, _ = callAPI(t, bot, "token", "sendMessage", `{"chat_id":1,"text":""}`)
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, "Bad Request: message text is empty", env.Description)
+}
+
+func TestAPI_SendMessageReachesClients(t *testing.T) {
+	t.Parallel()
+	bot := newTestBot(t)
+	defer bot.Close(context.Background())
+	conn := dialWS(t, bot)
+	defer conn.Close()
+
+	status, _, result := callAPI(t, bot, "token", "sendMessage",
+		`{"chat_id":5,"text":"hi","reply_markup":{"inline_keyboard":[[{"text":"A","callback_data":"a"}]]}}`)
+	require.Equal(t, http.StatusOK, status)
+	var msg Message
+	require.NoError(t, json.Unmarshal(result, &msg))
+	require.Equal(t, int64(5), msg.Chat.ID)
+
+	// form encoding, as sent by curl or multipart-capable clients
+	resp, err := http.PostForm(bot.APIURL()+"/bottoken/sendMessage", url.Values{"chat_id": {"5"}, "text": {"42"}})
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	for _, want := range []string{"hi", "42"} {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, raw, err := conn.ReadMessage()
+		require.NoError(t, err)
+		var out outboundPayload
+		require.NoError(t, json.Unmarshal(raw, &out))
+		require.Equal(t, want, out.Text)
+	}
+}
+
+func TestAPI_GetUpdatesLongPolling(t *testing.T) {
+	t.Parallel()
+	bot := newTestBot(t)
+	defer bot.Close(context.Background())
+	conn := dialWS(t, bot)
+	defer conn.Close()
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = conn.WriteMessage(websocket.TextMessage, []byte(`{"chat_id":3,"text":"/start"}`))
+	}()
+
+	_, env, result := callAPI(t, bot, "token", "getUpdates", `{"timeout":2}`)
+	require.True(t, env.Ok)
+	var updates []Update
+	require.NoError(t, json.Unmarshal(result, &updates))
+	require.Len(t, updates, 1)
+	require.Equal(t, "/start", updates[0].Message.Text)
+
+	// unconfirmed updates are returned again, confirmed ones are dropped
+	_, _, result = callAPI(t, bot, "token", "getUpdates", `{}`)
+	require.NoError(t, json.Unmarshal(result, &updates))
+	require.Len(t, updates, 1)
+
+	body, _ := json.Marshal(GetUpdatesParams{Offset: int(updates[0].UpdateID) + 1})
+	_, _, result = callAPI(t, bot, "token", "getUpdates", string(body))
+	require.NoError(t, json.Unmarshal(result, &updates))
+	require.Empty(t, updates)
+}
+
+func TestAPI_DeleteMessages(t *testing.T) {
+	t.Parallel()
+	bot := newTestBot(t)
+	defer bot.Close(context.Background())
+
+	sent, err := bot.SendMessage(context.Background(), &SendMessageParams{ChatID: ChatID{ID: 9}, Text: "bye"})
+	require.NoError(t, err)
+
+	body := fmt.Sprintf(`{"chat_id":9,"message_ids":[%d]}`, sent.MessageID)
+	status, env, result := callAPI(t, bot, "token", "deleteMessages", body)
+	require.Equal(t, http.StatusOK, status)
+	require.True(t, env.Ok)
+	require.JSONEq(t, `true`, string(result))
+
+	status, env, _ = callAPI(t, bot, "token", "deleteMessage", fmt.Sprintf(`{"chat_id":9,"message_id":%d}`, sent.MessageID))
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, "Bad Request: message to delete not found", env.Description)
+}
@@ -2,7 +2,6 @@ package telemock
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"log"
@@ -10,65 +9,115 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+	util "github.com/teterevlev/telemock-go/internal/util"
 )
 
 type Bot struct {
+	token      string
+	me         User
 	addr       string
+	logDir     string
+	updatesBuf int
+	inMemory   bool
 	upgrader   websocket.Upgrader
 	mu         sync.RWMutex
-	clients    map[*websocket.Conn]struct{}
+	clients    map[*client]struct{}
+	clientQ    int
+	slowPolicy SlowClientPolicy
+	store      *chatStore
+	files      *fileStore
+	localDir   string // where file:// URLs may point, see WithLocalFiles
+	callbacks  *callbackStore
+	cbTimeout  time.Duration
+	clock      Clock
 	updates    chan Update
+	overflow   OverflowPolicy
+	dropped    atomic.Uint64
+	outbox     chan Event
+	outboxBuf  int
+	outboxLost atomic.Uint64
 	nextUpdID  int64
 	nextMsgID  int64
+	nextCbID   int64
 	httpServer *http.Server
 	listener   net.Listener
+	ready      chan struct{}
 	closed     chan struct{}
+	done       chan struct{} // closed when Close starts
+	closeOnce  sync.Once
+	lifeMu     sync.RWMutex // held for reading while sending on updates
+	readers    sync.WaitGroup
 	logger     *log.Logger
 	logFile    *os.File
 	logMu      sync.Mutex
+	pollMu     sync.Mutex
+	polled     []Update
 }
 
 // NewBot starts telemock WS server listening on default address ":8765".
-// token is ignored but kept for API compatibility.
-func NewBot(token string) (*Bot, error) {
-	addr := ":8765"
-
+// The same server answers Telegram Bot API calls at /bot<token>/<method>, so a
+// real telego client can point its API server URL at telemock. A numeric token
+// prefix ("123:abc") becomes the bot's user ID. Options override the defaults.
+func NewBot(token string, opts ...BotOption) (*Bot, error) {
+	botID, _, _ := strings.Cut(token, ":")
 	b := &Bot{
-		addr:     addr,
-		upgrader: websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }},
-		clients:  make(map[*websocket.Conn]struct{}),
-		updates:  make(chan Update, 256),
-		closed:   make(chan struct{}),
-		logger:   log.Default(),
+		token:      token,
+		me:         User{ID: util.ParseToInt64(botID), IsBot: true, FirstName: "Telemock", Username: "telemock_bot"},
+		addr:       ":8765",
+		logDir:     "log",
+		updatesBuf: 256,
+		outboxBuf:  256,
+		upgrader:   websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }},
+		clients:    make(map[*client]struct{}),
+		store:      newChatStore(),
+		files:      newFileStore(),
+		callbacks:  newCallbackStore(),
+		cbTimeout:  defaultCallbackTimeout,
+		clock:      wallClock{},
+		clientQ:    256,
+		ready:      make(chan struct{}),
+		closed:     make(chan struct{}),
+		done:       make(chan struct{}),
+		logger:     log.Default(),
 	}
-
-	// initialize JSONL logging into log/<datetime>.jsonl
-	if err := os.MkdirAll("log", 0o755); err != nil {
-		b.logger.Printf("telemock: failed to create log dir: %v\n", err)
-	} else {
-		fname := time.Now().Format("2006-01-02T15-04-05Z07:00") + ".jsonl"
-		path := filepath.Join("log", fname)
-		f, err := os.Create(path)
-		if err != nil {
-			b.logger.Printf("telemock: failed to create log file: %v\n", err)
-		} else {
-			b.logFile = f
+	for _, opt := range opts {
+		if err := opt(b); err != nil {
+			return nil, err
 		}
 	}
+	b.updates = make(chan Update, b.updatesBuf)
+	b.outbox = make(chan Event, b.outboxBuf)
+	addr := b.addr
+
+	// initialize JSONL logging into <logDir>/<datetime>.jsonl
+	if b.logDir != "" {
+		b.openLogFile()
+	}
+
+	if b.inMemory {
+		close(b.ready)
+		close(b.closed)
+		return b, nil
+	}
 
 	ln, err := net.Listen("tcp", addr)
 	if err != nil {
+		if b.logFile != nil {
+			_ = b.logFile.Close()
+		}
 		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
 	}
 	b.listener = ln
 
 	mux := http.NewServeMux()
-	mux.HandleFunc("/", b.handleWS)
+	mux.HandleFunc("/", b.serveHTTP)
 
 	srv := &http.Server{
 		Handler: mux,
@@ -76,7 +125,8 @@ func NewBot(token string) (*Bot, error) {
 	b.httpServer = srv
 
 	go func() {
-		b.logger.Printf("telemock: WebSocket server starting on %s\n", addr)
+		b.logger.Printf("telemock: WebSocket server listening on %s\n", b.URL())
+		close(b.ready)
 		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			b.logger.Printf("telemock: server error: %v\n", err)
 		}
@@ -86,8 +136,99 @@ func NewBot(token string) (*Bot, error) {
 	return b, nil
 }
 
-func (b *Bot) UpdatesViaLongPolling(ctx context.Context, _ *GetUpdatesParams) (<-chan Update, error) {
-	out := make(chan Update)
+// Addr returns the address the server is bound to, e.g. "127.0.0.1:41523".
+// It is empty for in-memory bots.
+func (b *Bot) Addr() string {
+	if b.listener == nil {
+		return ""
+	}
+	return b.listener.Addr().String()
+}
+
+// URL returns the websocket URL clients should dial. An unspecified listen
+// host such as ":0" is reported as 127.0.0.1.
+func (b *Bot) URL() string {
+	if b.listener == nil {
+		return ""
+	}
+	host, port, err := net.SplitHostPort(b.Addr())
+	if err != nil {
+		return "ws://" + b.Addr() + "/"
+	}
+	if ip := net.ParseIP(host); ip == nil || ip.IsUnspecified() {
+		host = "127.0.0.1"
+	}
+	return "ws://" + net.JoinHostPort(host, port) + "/"
+}
+
+// APIURL returns the base URL of the Bot API endpoint, suitable for
+// telego.WithAPIServer. It is empty for in-memory bots.
+func (b *Bot) APIURL() string {
+	if u := b.URL(); u != "" {
+		return "http" + strings.TrimSuffix(strings.TrimPrefix(u, "ws"), "/")
+	}
+	return ""
+}
+
+// Ready is closed once the server accepts connections.
+func (b *Bot) Ready() <-chan struct{} {
+	return b.ready
+}
+
+// WaitReady blocks until the server accepts connections or ctx is done.
+func (b *Bot) WaitReady(ctx context.Context) error {
+	select {
+	case <-b.ready:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Inject delivers an update as if a client had sent it. A zero UpdateID is
+// assigned from the bot's sequence; a new message without MessageID or Date
+// gets a free ID in its chat and the current time.
+func (b *Bot) Inject(u Update) error {
+	if u.UpdateID == 0 {
+		u.UpdateID = b.nextUpdateID()
+	}
+	for _, m := range []**Message{&u.Message, &u.ChannelPost} {
+		if *m == nil {
+			continue
+		}
+		// копия: сообщение вызывающего не меняем
+		msg := **m
+		if msg.MessageID == 0 {
+			msg.MessageID = b.freeMessageID(msg.Chat.ID)
+		}
+		if msg.Date == 0 {
+			msg.Date = b.now().Unix()
+		}
+		*m = &msg
+	}
+	if err := b.enqueueUpdate(u); !errors.Is(err, errUpdateDropped) {
+		return err
+	}
+	return nil
+}
+
+// Outbox returns the stream of everything the bot sends. It holds 256 events,
+// see WithOutboxBuffer; when nobody drains it the oldest are discarded.
+func (b *Bot) Outbox() <-chan Event {
+	return b.outbox
+}
+
+// UpdatesViaLongPolling streams updates until ctx is done. Updates come from
+// memory, so params and the interval and retry options have no effect; only
+// WithLongPollingBuffer matters.
+func (b *Bot) UpdatesViaLongPolling(ctx context.Context, _ *GetUpdatesParams, options ...LongPollingOption) (<-chan Update, error) {
+	var lp longPolling
+	for _, opt := range options {
+		if err := opt(&lp); err != nil {
+			return nil, err
+		}
+	}
+	out := make(chan Update, lp.updateChanBuffer)
 	go func() {
 		defer close(out)
 		for {
@@ -109,110 +250,321 @@ func (b *Bot) UpdatesViaLongPolling(ctx context.Context, _ *GetUpdatesParams) (<
 	return out, nil
 }
 
+// GetMe returns the bot's own user.
+func (b *Bot) GetMe(ctx context.Context) (*User, error) {
+	me := b.me
+	return &me, nil
+}
+
 func (b *Bot) SendMessage(ctx context.Context, params *SendMessageParams) (*Message, error) {
 	if params == nil {
 		return nil, errors.New("nil params")
 	}
-	msgID := atomic.AddInt64(&b.nextMsgID, 1)
+	if params.ChatID.ID == 0 {
+		return nil, errBadRequest("chat not found")
+	}
+	if params.Text == "" {
+		return nil, errBadRequest("message text is empty")
+	}
+	if err := validateInlineKeyboard(params.ReplyMarkup); err != nil {
+		return nil, err
+	}
+	message, err := b.newMessage(params.ChatID, params.ReplyParameters, params.ReplyMarkup)
+	if err != nil {
+		return nil, err
+	}
+	message.Text, message.Entities = params.Text, params.Entities
+	return b.deliver(message, params.ReplyMarkup)
+}
+
+// newMessage starts a bot message in chatID if the bot may write there; the
+// caller fills in the content. A reply carries the stored message replied
+// to. Only an inline keyboard stays attached to the message. In channels the
+// message is sent on behalf of the channel.
+func (b *Bot) newMessage(chatID ChatID, reply *ReplyParameters, markup ReplyMarkup) (*Message, error) {
+	chat, err := b.chat(chatID.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := b.canPost(chat); err != nil {
+		return nil, err
+	}
 	message := &Message{
-		MessageID: msgID,
-		Chat:      Chat{ID: params.ChatID.ID},
-		Text:      params.Text,
-		From:      &User{ID: 0, Name: "bot"},
+		MessageID:   b.freeMessageID(chat.ID),
+		Date:        b.now().Unix(),
+		Chat:        chat,
+		ReplyMarkup: inlineKeyboard(markup),
+	}
+	if chat.Type == ChatTypeChannel {
+		message.SenderChat = &chat
+	} else {
+		me := b.me
+		message.From = &me
 	}
+	if err := b.attachReply(message, reply); err != nil {
+		return nil, err
+	}
+	return message, nil
+}
+
+// nextMessageID assigns message IDs, shared by bot and user messages
+func (b *Bot) nextMessageID() int {
+	return int(atomic.AddInt64(&b.nextMsgID, 1))
+}
 
-	out := outboundPayload{
-		ChatID:           params.ChatID.ID,
-		Text:             params.Text,
-		From:             "bot",
-		MessageID:        msgID,
-		ReplyToMessageID: params.ReplyToMessageID,
+// freeMessageID assigns the next message ID not yet used in chatID: clients
+// may choose their own IDs
+func (b *Bot) freeMessageID(chatID int64) int {
+	for {
+		id := b.nextMessageID()
+		if _, ok := b.store.get(chatID, id); !ok {
+			return id
+		}
 	}
+}
+
+func (b *Bot) nextUpdateID() int {
+	return int(atomic.AddInt64(&b.nextUpdID, 1))
+}
+
+func (b *Bot) nextCallbackID() string {
+	return "cb-" + strconv.FormatInt(atomic.AddInt64(&b.nextCbID, 1), 10)
+}
 
-	if params.ReplyMarkup != nil {
-		out.ReplyMarkup = params.ReplyMarkup
+// deliver stores a new bot message, applies its reply keyboard markup to the
+// chat and publishes both
+func (b *Bot) deliver(message *Message, markup ReplyMarkup) (*Message, error) {
+	markup = normalizeReplyMarkup(markup)
+	b.store.put(*message, true)
+	b.store.applyMarkup(message.Chat.ID, markup)
+	if err := b.publish(Event{Type: EventMessage, Message: message, ReplyMarkup: markup}); err != nil {
+		return nil, err
 	}
+	return message, nil
+}
 
-	if params.ReplyToMessageID != 0 {
-		out.IsReply = true
+// Close stops the server, disconnects all clients and closes the updates
+// channel once every websocket reader has stopped. Pending getUpdates long
+// polls return empty right away. It gives up waiting when ctx
+// is done; shutdown then completes in the background. Calling Close again is a no-op.
+func (b *Bot) Close(ctx context.Context) error {
+	first := false
+	b.closeOnce.Do(func() {
+		first = true
+		close(b.done)
+	})
+	if !first {
+		return nil
+	}
+	for _, cq := range b.callbacks.unanswered() {
+		b.logger.Printf("telemock: callback query %s (%q) was never answered\n", cq.ID, cq.Data)
 	}
 
-	b.mu.RLock()
-	conns := make([]*websocket.Conn, 0, len(b.clients))
+	var err error
+	if b.httpServer != nil {
+		err = b.httpServer.Shutdown(ctx)
+	}
+	// close all websockets so their read loops return
+	b.mu.Lock()
 	for c := range b.clients {
-		conns = append(conns, c)
+		c.stop()
 	}
-	b.mu.RUnlock()
+	b.clients = map[*client]struct{}{}
+	b.mu.Unlock()
 
-	if len(conns) == 0 {
-		return message, nil
-	}
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		b.readers.Wait()
 
-	data, err := json.Marshal(out)
-	if err != nil {
-		return nil, err
-	}
+		// no sender can be past the done check once the write lock is held
+		b.lifeMu.Lock()
+		close(b.updates)
+		b.lifeMu.Unlock()
 
-	if b.logFile != nil {
 		b.logMu.Lock()
-		_, _ = b.logFile.Write(data)
-		_, _ = b.logFile.Write([]byte("\n"))
+		if b.logFile != nil {
+			_ = b.logFile.Close()
+			b.logFile = nil
+		}
 		b.logMu.Unlock()
+
+		<-b.closed
+	}()
+
+	select {
+	case <-finished:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
 	}
+}
 
-	for _, c := range conns {
-		_ = c.SetWriteDeadline(time.Now().Add(2 * time.Second))
-		if err := c.WriteMessage(websocket.TextMessage, data); err != nil {
-			b.logger.Printf("telemock: write error: %v\n", err)
-			go b.removeClient(c)
-		}
+// isClosed reports whether Close has been called
+func (b *Bot) isClosed() bool {
+	select {
+	case <-b.done:
+		return true
+	default:
+		return false
 	}
+}
 
-	return message, nil
+// DroppedUpdates returns how many updates were lost because the updates
+// buffer was full, whether discarded or rejected by the overflow policy.
+func (b *Bot) DroppedUpdates() uint64 {
+	return b.dropped.Load()
 }
 
-func (b *Bot) AnswerCallbackQuery(ctx context.Context, callbackID string, text string) error {
-	_ = callbackID
-	_ = text
-	return nil
+// DroppedEvents returns how many events were discarded from the outbox
+// because nobody drained it.
+func (b *Bot) DroppedEvents() uint64 {
+	return b.outboxLost.Load()
 }
 
-func (b *Bot) Close(ctx context.Context) error {
-	if b.httpServer != nil {
-		_ = b.httpServer.Shutdown(ctx)
+// enqueueUpdate hands an update to the bot, applying the overflow policy if the buffer is full.
+// It returns errUpdateDropped when OverflowDropNewest discarded the update.
+func (b *Bot) enqueueUpdate(upd Update) error {
+	b.lifeMu.RLock()
+	defer b.lifeMu.RUnlock()
+	if b.isClosed() {
+		return ErrBotClosed
 	}
-	// close all websockets
-	b.mu.Lock()
-	for c := range b.clients {
-		_ = c.Close()
+	// записываем до отправки: бот может ответить на апдейт раньше, чем
+	// вернётся отправка. Апдейт, так и не попавший в очередь, забываем.
+	undo := b.record(upd)
+	select {
+	case b.updates <- upd:
+		return nil
+	default:
 	}
-	b.clients = map[*websocket.Conn]struct{}{}
-	b.mu.Unlock()
 
-	// close updates channel
-	close(b.updates)
+	switch b.overflow {
+	case OverflowBlock:
+	case OverflowDropNewest:
+		undo()
+		b.dropped.Add(1)
+		b.logger.Printf("telemock: updates buffer full, dropped update %d\n", upd.UpdateID)
+		return errUpdateDropped
+	case OverflowReject:
+		undo()
+		b.dropped.Add(1)
+		return ErrUpdatesFull
+	default:
+		if old, ok := b.drainOneUpdate(); ok {
+			b.forget(old)
+			b.dropped.Add(1)
+			b.logger.Printf("telemock: updates buffer full, dropped oldest update\n")
+		}
+	}
+	select {
+	case b.updates <- upd:
+		return nil
+	case <-b.done:
+		undo()
+		return ErrBotClosed
+	}
+}
 
-	// close log file
-	if b.logFile != nil {
-		_ = b.logFile.Close()
+// record stores user messages and callback queries carried by an update so
+// the bot can later reference them. undo reverts it for an update the bot
+// never gets.
+func (b *Bot) record(upd Update) (undo func()) {
+	if cq := upd.CallbackQuery; cq != nil {
+		b.callbacks.add(cq, nil, b.now(), b.cbTimeout)
+		return func() { b.callbacks.remove(cq.ID) }
 	}
+	m := upd.message()
+	if m == nil {
+		return func() {}
+	}
+	msg := *m
+	if msg.Date == 0 {
+		msg.Date = b.now().Unix()
+	}
+	b.store.put(msg, false)
+	if upd.Message != nil {
+		b.store.useKeyboard(&msg)
+	}
+	return func() { b.store.revert(msg.Chat.ID, msg.MessageID) }
+}
 
-	// wait for serve goroutine to finish
+// forget removes what record stored for an update evicted from the queue. An
+// evicted edit is kept: the message itself did reach the bot.
+func (b *Bot) forget(upd Update) {
+	switch {
+	case upd.CallbackQuery != nil:
+		b.callbacks.remove(upd.CallbackQuery.ID)
+	case upd.Message != nil:
+		b.store.drop(upd.Message.Chat.ID, upd.Message.MessageID)
+	case upd.ChannelPost != nil:
+		b.store.drop(upd.ChannelPost.Chat.ID, upd.ChannelPost.MessageID)
+	}
+}
+
+// ReplyKeyboard returns the custom reply keyboard currently shown in a chat,
+// or nil when the chat has none.
+func (b *Bot) ReplyKeyboard(chatID int64) *ReplyKeyboardMarkup {
+	return b.store.keyboard(chatID)
+}
+
+// now is the bot's notion of the current time, see Clock
+func (b *Bot) now() time.Time {
+	return b.clock.Now()
+}
+
+// publish fans a bot event out to the outbox and every websocket client
+func (b *Bot) publish(ev Event) error {
+	if b.isClosed() {
+		return ErrBotClosed
+	}
+	if ev.Message != nil && ev.FileURL == "" {
+		ev.FileURL = b.mediaURL(ev.Message)
+	}
 	select {
-	case <-b.closed:
-	case <-time.After(2 * time.Second):
+	case b.outbox <- ev:
+	default:
+		// discard the oldest event to make room
+		b.outboxLost.Add(1)
+		select {
+		case <-b.outbox:
+		default:
+		}
+		select {
+		case b.outbox <- ev:
+		default:
+		}
 	}
+	return b.broadcastWS(ev)
+}
 
-	return nil
+// openLogFile creates <logDir>/<datetime>.jsonl, logging failures instead of returning them
+func (b *Bot) openLogFile() {
+	if err := os.MkdirAll(b.logDir, 0o755); err != nil {
+		b.logger.Printf("telemock: failed to create log dir: %v\n", err)
+		return
+	}
+	fname := time.Now().Format("2006-01-02T15-04-05Z07:00") + ".jsonl"
+	f, err := os.Create(filepath.Join(b.logDir, fname))
+	if err != nil {
+		b.logger.Printf("telemock: failed to create log file: %v\n", err)
+		return
+	}
+	b.logFile = f
 }
 
 // logRequest writes one JSON line with incoming raw payload
 func (b *Bot) logRequest(remote string, raw []byte) {
+	b.logLine(raw)
+}
+
+// logLine appends one JSON line to the log file, if logging is enabled
+func (b *Bot) logLine(data []byte) {
+	b.logMu.Lock()
+	defer b.logMu.Unlock()
 	if b.logFile == nil {
 		return
 	}
-	b.logMu.Lock()
-	defer b.logMu.Unlock()
-	_, _ = b.logFile.Write(raw)
+	_, _ = b.logFile.Write(data)
 	_, _ = b.logFile.Write([]byte("\n"))
 }
@@ -0,0 +1,222 @@
+package telemock
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxCallbackDataLength is the Bot API limit on callback_data, in bytes
+const maxCallbackDataLength = 64
+
+// Press actions tell a client what pressing an inline button does
+const (
+	PressCallback           = "callback"
+	PressOpenURL            = "open_url"
+	PressWebApp             = "web_app"
+	PressLoginURL           = "login_url"
+	PressInlineQuery        = "switch_inline_query"
+	PressInlineQueryCurrent = "switch_inline_query_current_chat"
+	PressCopyText           = "copy_text"
+	PressGame               = "callback_game"
+	PressPay                = "pay"
+)
+
+// pressResult is the outcome of pressing an inline button: an update for the
+// bot, or something the client does by itself
+type pressResult struct {
+	Action   string
+	URL      string
+	Query    string // text the client puts into the input field
+	CopyText string
+	Update   *Update
+}
+
+// buttonKinds lists the optional fields a button sets
+func buttonKinds(btn InlineKeyboardButton) []string {
+	var kinds []string
+	add := func(set bool, kind string) {
+		if set {
+			kinds = append(kinds, kind)
+		}
+	}
+	add(btn.URL != "", "url")
+	add(btn.CallbackData != "", "callback_data")
+	add(btn.WebApp != nil, "web_app")
+	add(btn.LoginURL != nil, "login_url")
+	add(btn.SwitchInlineQuery != nil, "switch_inline_query")
+	add(btn.SwitchInlineQueryCurrentChat != nil, "switch_inline_query_current_chat")
+	add(btn.SwitchInlineQueryChosenChat != nil, "switch_inline_query_chosen_chat")
+	add(btn.CopyText != nil, "copy_text")
+	add(btn.CallbackGame != nil, "callback_game")
+	add(btn.Pay, "pay")
+	return kinds
+}
+
+// validateInlineKeyboard rejects buttons Telegram wouldn't accept: each one
+// needs a text and exactly one optional field
+func validateInlineKeyboard(markup ReplyMarkup) error {
+	k := inlineKeyboard(markup)
+	if k == nil {
+		return nil
+	}
+	for _, row := range k.InlineKeyboard {
+		for _, btn := range row {
+			if err := validateButton(btn); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateButton(btn InlineKeyboardButton) error {
+	if btn.Text == "" {
+		return errBadRequest("can't parse inline keyboard button: Text must be non-empty")
+	}
+	switch kinds := buttonKinds(btn); len(kinds) {
+	case 0:
+		return errBadRequest("text buttons are unallowed in the inline keyboard")
+	case 1:
+	default:
+		return errBadRequest("can't parse inline keyboard button: only one of " +
+			strings.Join(kinds, ", ") + " can be specified")
+	}
+	switch {
+	case len(btn.CallbackData) > maxCallbackDataLength:
+		return errBadRequest("BUTTON_DATA_INVALID")
+	case btn.URL != "" && !validButtonURL(btn.URL, "http", "https", "tg"),
+		btn.LoginURL != nil && !validButtonURL(btn.LoginURL.URL, "http", "https"):
+		return errBadRequest("BUTTON_URL_INVALID")
+	case btn.WebApp != nil && !validButtonURL(btn.WebApp.URL, "https"):
+		return errBadRequest("inline keyboard button Web App URL '" + btn.WebApp.URL +
+			"' is invalid: Only HTTPS links are allowed")
+	case btn.CopyText != nil && (btn.CopyText.Text == "" || len([]rune(btn.CopyText.Text)) > 256):
+		return errBadRequest("COPY_TEXT_INVALID")
+	}
+	return nil
+}
+
+func validButtonURL(raw string, schemes ...string) bool {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return false
+	}
+	for _, s := range schemes {
+		if strings.EqualFold(u.Scheme, s) && (u.Host != "" || s == "tg") {
+			return true
+		}
+	}
+	return false
+}
+
+// findButton returns the inline button labelled text under a stored message
+func (b *Bot) findButton(chatID int64, msgID int, text string) (InlineKeyboardButton, error) {
+	msg, ok := b.store.get(chatID, msgID)
+	if !ok {
+		return InlineKeyboardButton{}, errBadRequest("message not found")
+	}
+	if msg.ReplyMarkup != nil {
+		for _, row := range msg.ReplyMarkup.InlineKeyboard {
+			for _, btn := range row {
+				if btn.Text == text {
+					return btn, nil
+				}
+			}
+		}
+	}
+	return InlineKeyboardButton{}, errBadRequest("button not found")
+}
+
+// pressButton simulates what a Telegram client does when the user presses the
+// inline button labelled text. Callback and game buttons produce a callback
+// query; the others are handled by the client, with telemock filling in what
+// Telegram's servers would: a signed login URL or the inline query text.
+// fromID is the member pressing the button, see sender.
+func (b *Bot) pressButton(chatID, fromID int64, msgID int, text string) (pressResult, error) {
+	chat, err := b.chat(chatID)
+	if err != nil {
+		return pressResult{}, err
+	}
+	from, err := b.sender(chat, fromID, false)
+	if err != nil {
+		return pressResult{}, err
+	}
+	btn, err := b.findButton(chatID, msgID, text)
+	if err != nil {
+		return pressResult{}, err
+	}
+	mention := "@" + b.me.Username + " "
+	switch {
+	case btn.CallbackData != "":
+		upd, err := b.callbackUpdate(chatID, fromID, msgID, "", btn.CallbackData)
+		return pressResult{Action: PressCallback, Update: &upd}, err
+	case btn.CallbackGame != nil:
+		// игр нет: короткое имя игры заменяет текст кнопки
+		upd, err := b.callbackUpdate(chatID, fromID, msgID, "", "")
+		if err != nil {
+			return pressResult{}, err
+		}
+		upd.CallbackQuery.GameShortName = btn.Text
+		return pressResult{Action: PressGame, Update: &upd}, nil
+	case btn.URL != "":
+		return pressResult{Action: PressOpenURL, URL: btn.URL}, nil
+	case btn.WebApp != nil:
+		return pressResult{Action: PressWebApp, URL: btn.WebApp.URL}, nil
+	case btn.LoginURL != nil:
+		return pressResult{Action: PressLoginURL, URL: b.loginURL(btn.LoginURL.URL, from)}, nil
+	case btn.SwitchInlineQuery != nil:
+		return pressResult{Action: PressInlineQuery, Query: mention + *btn.SwitchInlineQuery}, nil
+	case btn.SwitchInlineQueryCurrentChat != nil:
+		return pressResult{Action: PressInlineQueryCurrent, Query: mention + *btn.SwitchInlineQueryCurrentChat}, nil
+	case btn.SwitchInlineQueryChosenChat != nil:
+		return pressResult{Action: PressInlineQuery, Query: mention + btn.SwitchInlineQueryChosenChat.Query}, nil
+	case btn.CopyText != nil:
+		return pressResult{Action: PressCopyText, CopyText: btn.CopyText.Text}, nil
+	case btn.Pay:
+		return pressResult{Action: PressPay}, nil
+	}
+	return pressResult{}, errBadRequest("button not found")
+}
+
+// loginURL appends the user's data to a login_url the way Telegram does,
+// signed like the Login Widget so the bot can check the hash with its token
+func (b *Bot) loginURL(raw string, user User) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return raw
+	}
+	fields := map[string]string{
+		"id":        strconv.FormatInt(user.ID, 10),
+		"auth_date": strconv.FormatInt(b.now().Unix(), 10),
+	}
+	if user.FirstName != "" {
+		fields["first_name"] = user.FirstName
+	}
+	if user.LastName != "" {
+		fields["last_name"] = user.LastName
+	}
+	if user.Username != "" {
+		fields["username"] = user.Username
+	}
+	lines := make([]string, 0, len(fields))
+	for k, v := range fields {
+		lines = append(lines, k+"="+v)
+	}
+	sort.Strings(lines)
+	secret := sha256.Sum256([]byte(b.token))
+	mac := hmac.New(sha256.New, secret[:])
+	mac.Write([]byte(strings.Join(lines, "\n")))
+
+	q := u.Query()
+	for k, v := range fields {
+		q.Set(k, v)
+	}
+	q.Set("hash", hex.EncodeToString(mac.Sum(nil)))
+	u.RawQuery = q.Encode()
+	return u.String()
+}
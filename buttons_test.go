@@ -0,0 +1,123 @@
+package telemock
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInlineKeyboard_Validation(t *testing.T) {
+	t.Parallel()
+	bot, err := NewBot("token", WithoutListener(), WithoutLogFile(), WithLogger(nil))
+	require.NoError(t, err)
+	defer bot.Close(context.Background())
+	query := ""
+
+	for _, tc := range []struct {
+		btn  InlineKeyboardButton
+		want string
+	}{
+		{InlineKeyboardButton{Text: "ok", CallbackData: strings.Repeat("x", 64)}, ""},
+		{InlineKeyboardButton{Text: "ok", SwitchInlineQuery: &query}, ""},
+		{InlineKeyboardButton{Text: "ok", Pay: true}, ""},
+		{InlineKeyboardButton{Text: "long", CallbackData: strings.Repeat("x", 65)}, "Bad Request: BUTTON_DATA_INVALID"},
+		{InlineKeyboardButton{Text: "plain"}, "Bad Request: text buttons are unallowed in the inline keyboard"},
+		{InlineKeyboardButton{Text: "two", URL: "https://example.com", CallbackData: "x"},
+			"Bad Request: can't parse inline keyboard button: only one of url, callback_data can be specified"},
+		{InlineKeyboardButton{Text: "bad", URL: "example.com"}, "Bad Request: BUTTON_URL_INVALID"},
+		{InlineKeyboardButton{Text: "app", WebApp: &WebAppInfo{URL: "http://example.com"}},
+			"Bad Request: inline keyboard button Web App URL 'http://example.com' is invalid: Only HTTPS links are allowed"},
+		{InlineKeyboardButton{CallbackData: "x"}, "Bad Request: can't parse inline keyboard button: Text must be non-empty"},
+	} {
+		markup := &InlineKeyboardMarkup{InlineKeyboard: [][]InlineKeyboardButton{{tc.btn}}}
+		_, err := bot.SendMessage(context.Background(), &SendMessageParams{ChatID: ChatID{ID: 1}, Text: "hi", ReplyMarkup: markup})
+		if tc.want == "" {
+			require.NoError(t, err, tc.btn.Text)
+			continue
+		}
+		var apiErr *Error
+		require.ErrorAs(t, err, &apiErr)
+		require.Equal(t, tc.want, apiErr.Description)
+	}
+}
+
+func TestPressButton(t *testing.T) {
+	t.Parallel()
+	bot := newTestBot(t)
+	defer bot.Close(context.Background())
+	conn, _, err := websocket.DefaultDialer.Dial(bot.URL()+"?v=1", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	waitClients(t, bot, 1)
+
+	updates, err := bot.UpdatesViaLongPolling(context.Background(), &GetUpdatesParams{})
+	require.NoError(t, err)
+
+	query := "cats"
+	msg, err := bot.SendMessage(context.Background(), &SendMessageParams{ChatID: ChatID{ID: 3}, Text: "menu",
+		ReplyMarkup: &InlineKeyboardMarkup{InlineKeyboard: [][]InlineKeyboardButton{
+			{{Text: "Yes", CallbackData: "yes"}, {Text: "Site", URL: "https://example.com"}},
+			{{Text: "Login", LoginURL: &LoginURL{URL: "https://example.com/login?next=1"}}},
+			{{Text: "Search", SwitchInlineQueryCurrentChat: &query}, {Text: "Copy", CopyText: &CopyTextButton{Text: "promo"}}},
+			{{Text: "Play", CallbackGame: &CallbackGame{}}},
+		}}})
+	require.NoError(t, err)
+	readEnvelope(t, conn)
+
+	press := func(text string) (envelope, ackPayload) {
+		t.Helper()
+		frame, _ := json.Marshal(map[string]any{"type": "press", "id": text,
+			"payload": map[string]any{"chat_id": 3, "message_id": msg.MessageID, "text": text}})
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, frame))
+		env := readEnvelope(t, conn)
+		var ack ackPayload
+		require.NoError(t, json.Unmarshal(env.Payload, &ack))
+		return env, ack
+	}
+
+	_, ack := press("Yes")
+	require.Equal(t, PressCallback, ack.Action)
+	upd := <-updates
+	require.Equal(t, ack.CallbackQueryID, upd.CallbackQuery.ID)
+	require.Equal(t, "yes", upd.CallbackQuery.Data)
+	require.Equal(t, "menu", upd.CallbackQuery.Message.Message().Text)
+
+	_, ack = press("Site")
+	require.Equal(t, ackPayload{Action: PressOpenURL, URL: "https://example.com"}, ack)
+
+	_, ack = press("Search")
+	require.Equal(t, "@telemock_bot cats", ack.Query)
+
+	_, ack = press("Copy")
+	require.Equal(t, "promo", ack.CopyText)
+
+	_, ack = press("Play")
+	require.Equal(t, PressGame, ack.Action)
+	upd = <-updates
+	require.Equal(t, "Play", upd.CallbackQuery.GameShortName)
+
+	// the login data is signed like the Login Widget's
+	_, ack = press("Login")
+	u, err := url.Parse(ack.URL)
+	require.NoError(t, err)
+	q := u.Query()
+	require.Equal(t, "1", q.Get("next"))
+	require.Equal(t, "3", q.Get("id"))
+	check := "auth_date=" + q.Get("auth_date") + "\nid=3"
+	secret := sha256.Sum256([]byte("token"))
+	mac := hmac.New(sha256.New, secret[:])
+	mac.Write([]byte(check))
+	require.Equal(t, hex.EncodeToString(mac.Sum(nil)), q.Get("hash"))
+
+	env, _ := press("Nope")
+	require.Equal(t, "error", env.Type)
+	require.Contains(t, string(env.Payload), "Bad Request: button not found")
+}
@@ -0,0 +1,207 @@
+package telemock
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// defaultCallbackTimeout is how long a callback query can be answered
+const defaultCallbackTimeout = 15 * time.Second
+
+// maxAnswerTextLength is the Bot API limit on callback answer texts
+const maxAnswerTextLength = 200
+
+var errQueryTooOld = errBadRequest("query is too old and response timeout expired or query ID is invalid")
+
+// CallbackAnswer is the bot's answer to a callback query, as delivered to the
+// client that pressed the button.
+type CallbackAnswer struct {
+	CallbackQueryID string `json:"callback_query_id"`
+	ChatID          int64  `json:"chat_id"`
+	MessageID       int    `json:"message_id,omitempty"`
+	Text            string `json:"text,omitempty"`
+	ShowAlert       bool   `json:"show_alert,omitempty"` // an alert instead of a toast
+	URL             string `json:"url,omitempty"`
+	CacheTime       int    `json:"cache_time,omitempty"`
+}
+
+// pendingCallback is a callback query the bot received
+type pendingCallback struct {
+	query  CallbackQuery
+	chatID int64
+	sent   time.Time
+	client *client // who pressed the button; nil for injected updates
+	answer *CallbackAnswer
+}
+
+// callbackStore keeps callback queries until they time out. Queries that
+// expired without an answer move to missed, so they can still be reported.
+type callbackStore struct {
+	mu     sync.Mutex
+	byID   map[string]*pendingCallback
+	order  []string
+	missed []CallbackQuery
+}
+
+func newCallbackStore() *callbackStore {
+	return &callbackStore{byID: make(map[string]*pendingCallback)}
+}
+
+// add registers a query unless it is known already
+func (s *callbackStore) add(cq *CallbackQuery, c *client, at time.Time, timeout time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(at, timeout)
+	if _, ok := s.byID[cq.ID]; ok {
+		return
+	}
+	p := &pendingCallback{query: *cq, chatID: cq.From.ID, sent: at, client: c}
+	if cq.Message != nil {
+		p.chatID = cq.Message.GetChat().ID
+	}
+	s.byID[cq.ID] = p
+	s.order = append(s.order, cq.ID)
+}
+
+// remove forgets a query
+func (s *callbackStore) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.byID[id]; !ok {
+		return
+	}
+	delete(s.byID, id)
+	s.order = slices.DeleteFunc(s.order, func(o string) bool { return o == id })
+}
+
+// prune forgets the queries older than timeout. Called with s.mu held.
+func (s *callbackStore) prune(now time.Time, timeout time.Duration) {
+	s.order = slices.DeleteFunc(s.order, func(id string) bool {
+		p := s.byID[id]
+		if now.Sub(p.sent) <= timeout {
+			return false
+		}
+		if p.answer == nil {
+			s.missed = append(s.missed, p.query)
+		}
+		delete(s.byID, id)
+		return true
+	})
+}
+
+// answer records the answer to a query that is still waiting for one
+func (s *callbackStore) answer(a CallbackAnswer, now time.Time, timeout time.Duration) (CallbackAnswer, *client, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.prune(now, timeout)
+	p, ok := s.byID[a.CallbackQueryID]
+	if !ok || p.answer != nil {
+		return a, nil, errQueryTooOld
+	}
+	if a.URL != "" && p.query.GameShortName == "" && !isBotLink(a.URL) {
+		return a, nil, errBadRequest("URL_INVALID")
+	}
+	a.ChatID = p.chatID
+	if p.query.Message != nil {
+		a.MessageID = p.query.Message.GetMessageID()
+	}
+	p.answer = &a
+	return a, p.client, nil
+}
+
+// isBotLink reports whether url is a t.me link, the only kind of URL a
+// non-game callback answer may open
+func isBotLink(url string) bool {
+	for _, prefix := range []string{"https://t.me/", "http://t.me/", "t.me/", "tg://"} {
+		if strings.HasPrefix(url, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *callbackStore) get(id string) (CallbackAnswer, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	p, ok := s.byID[id]
+	if !ok || p.answer == nil {
+		return CallbackAnswer{}, false
+	}
+	return *p.answer, true
+}
+
+func (s *callbackStore) unanswered() []CallbackQuery {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := slices.Clone(s.missed)
+	for _, id := range s.order {
+		if p := s.byID[id]; p.answer == nil {
+			out = append(out, p.query)
+		}
+	}
+	return out
+}
+
+// AnswerCallbackQuery answers a callback query the bot received. The answer is
+// sent to the client that pressed the button, which shows Text as a toast or,
+// with ShowAlert, as an alert. Like Telegram, a query can be answered once and
+// only within the callback timeout (see WithCallbackTimeout).
+func (b *Bot) AnswerCallbackQuery(ctx context.Context, params *AnswerCallbackQueryParams) error {
+	if params == nil {
+		return errors.New("nil params")
+	}
+	if b.isClosed() {
+		return ErrBotClosed
+	}
+	if utf8.RuneCountInString(params.Text) > maxAnswerTextLength {
+		return errBadRequest("MESSAGE_TOO_LONG")
+	}
+	answer := CallbackAnswer{
+		CallbackQueryID: params.CallbackQueryID,
+		Text:            params.Text,
+		ShowAlert:       params.ShowAlert,
+		URL:             params.URL,
+		CacheTime:       params.CacheTime,
+	}
+	answer, c, err := b.callbacks.answer(answer, b.now(), b.cbTimeout)
+	if err != nil {
+		return err
+	}
+	if c != nil {
+		b.sendAnswer(c, answer)
+	}
+	return nil
+}
+
+// answerFrameV0 is a callback answer in the v0 format
+type answerFrameV0 struct {
+	Type string `json:"type"`
+	CallbackAnswer
+}
+
+// sendAnswer delivers a callback answer to the client that pressed the button
+func (b *Bot) sendAnswer(c *client, a CallbackAnswer) {
+	if c.protocol() >= 1 {
+		b.sendFrame(c, newEnvelope("callback_answer", nil, a))
+		return
+	}
+	b.sendFrame(c, answerFrameV0{Type: "callback_answer", CallbackAnswer: a})
+}
+
+// CallbackAnswer returns how the bot answered the callback query id, if it did.
+// Answers are forgotten once their query times out.
+func (b *Bot) CallbackAnswer(id string) (CallbackAnswer, bool) {
+	return b.callbacks.get(id)
+}
+
+// UnansweredCallbacks returns the callback queries the bot hasn't answered,
+// including expired ones, in the order they arrived. Telegram clients show a
+// spinner on such buttons until the query times out.
+func (b *Bot) UnansweredCallbacks() []CallbackQuery {
+	return b.callbacks.unanswered()
+}
@@ -0,0 +1,109 @@
+package telemock
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnswerCallbackQuery(t *testing.T) {
+	t.Parallel()
+	bot, err := NewBot("token", WithoutListener(), WithoutLogFile(), WithLogger(nil),
+		WithCallbackTimeout(50*time.Millisecond))
+	require.NoError(t, err)
+	defer bot.Close(context.Background())
+	ctx := context.Background()
+
+	first, err := bot.callbackUpdate(4, 0, 0, "", "a")
+	require.NoError(t, err)
+	second, err := bot.callbackUpdate(4, 0, 0, "", "b")
+	require.NoError(t, err)
+	require.NoError(t, bot.Inject(first))
+	require.NoError(t, bot.Inject(second))
+	require.Len(t, bot.UnansweredCallbacks(), 2)
+
+	id := first.CallbackQuery.ID
+	require.NoError(t, bot.AnswerCallbackQuery(ctx, &AnswerCallbackQueryParams{CallbackQueryID: id, Text: "done", CacheTime: 5}))
+	answer, ok := bot.CallbackAnswer(id)
+	require.True(t, ok)
+	require.Equal(t, CallbackAnswer{CallbackQueryID: id, ChatID: 4, Text: "done", CacheTime: 5}, answer)
+	require.Equal(t, []CallbackQuery{*second.CallbackQuery}, bot.UnansweredCallbacks())
+
+	// a query is answered once, and only until it times out
+	require.ErrorIs(t, bot.AnswerCallbackQuery(ctx, &AnswerCallbackQueryParams{CallbackQueryID: id}), errQueryTooOld)
+	require.ErrorIs(t, bot.AnswerCallbackQuery(ctx, &AnswerCallbackQueryParams{CallbackQueryID: "nope"}), errQueryTooOld)
+	err = bot.AnswerCallbackQuery(ctx, &AnswerCallbackQueryParams{CallbackQueryID: second.CallbackQuery.ID, URL: "https://example.com"})
+	require.EqualError(t, err, `telemock: api: 400 "Bad Request: URL_INVALID"`)
+	time.Sleep(60 * time.Millisecond)
+	require.ErrorIs(t, bot.AnswerCallbackQuery(ctx, &AnswerCallbackQueryParams{CallbackQueryID: second.CallbackQuery.ID}), errQueryTooOld)
+	require.Len(t, bot.UnansweredCallbacks(), 1)
+}
+
+func TestAnswerCallbackQuery_PrunesExpired(t *testing.T) {
+	t.Parallel()
+	clock := NewMockClock(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC))
+	bot := newTestBot(t, WithClock(clock))
+	defer bot.Close(context.Background())
+	ctx := context.Background()
+
+	answered, err := bot.callbackUpdate(4, 0, 0, "", "a")
+	require.NoError(t, err)
+	missed, err := bot.callbackUpdate(4, 0, 0, "", "b")
+	require.NoError(t, err)
+	require.NoError(t, bot.Inject(answered))
+	require.NoError(t, bot.Inject(missed))
+	require.NoError(t, bot.AnswerCallbackQuery(ctx, &AnswerCallbackQueryParams{CallbackQueryID: answered.CallbackQuery.ID}))
+
+	clock.Advance(defaultCallbackTimeout + time.Second)
+	fresh, err := bot.callbackUpdate(4, 0, 0, "", "c")
+	require.NoError(t, err)
+	require.NoError(t, bot.Inject(fresh))
+	require.Len(t, bot.callbacks.byID, 1)
+	_, ok := bot.CallbackAnswer(answered.CallbackQuery.ID)
+	require.False(t, ok)
+	// a query that expired unanswered is still reported
+	require.Equal(t, []CallbackQuery{*missed.CallbackQuery, *fresh.CallbackQuery}, bot.UnansweredCallbacks())
+}
+
+func TestAnswerCallbackQuery_ReachesPresser(t *testing.T) {
+	t.Parallel()
+	bot := newTestBot(t)
+	defer bot.Close(context.Background())
+	presser, _, err := websocket.DefaultDialer.Dial(bot.URL()+"?v=1", nil)
+	require.NoError(t, err)
+	defer presser.Close()
+	legacy := dialWS(t, bot)
+	defer legacy.Close()
+	waitClients(t, bot, 2)
+
+	updates, err := bot.UpdatesViaLongPolling(context.Background(), &GetUpdatesParams{})
+	require.NoError(t, err)
+
+	require.NoError(t, presser.WriteMessage(websocket.TextMessage,
+		[]byte(`{"type":"callback","id":1,"payload":{"chat_id":6,"message_id":3,"data":"go"}}`)))
+	require.Equal(t, "ack", readEnvelope(t, presser).Type)
+	upd := <-updates
+
+	status, env, _ := callAPI(t, bot, "token", "answerCallbackQuery",
+		`{"callback_query_id":"`+upd.CallbackQuery.ID+`","text":"Saved!","show_alert":true}`)
+	require.Equal(t, http.StatusOK, status, env.Description)
+	frame := readEnvelope(t, presser)
+	require.Equal(t, "callback_answer", frame.Type)
+	var answer CallbackAnswer
+	require.NoError(t, json.Unmarshal(frame.Payload, &answer))
+	require.Equal(t, CallbackAnswer{CallbackQueryID: upd.CallbackQuery.ID, ChatID: 6, MessageID: 3, Text: "Saved!", ShowAlert: true}, answer)
+
+	// an observer of the chat doesn't see answers meant for someone else
+	legacy.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, _, err = legacy.ReadMessage()
+	require.Error(t, err)
+
+	status, env, _ = callAPI(t, bot, "token", "answerCallbackQuery", `{"callback_query_id":"`+upd.CallbackQuery.ID+`"}`)
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, "Bad Request: query is too old and response timeout expired or query ID is invalid", env.Description)
+}
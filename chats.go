@@ -0,0 +1,288 @@
+package telemock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ChatMembers is a group or channel with everyone who was ever in it, as sent
+// to websocket clients in "chat" frames.
+type ChatMembers struct {
+	Chat    Chat         `json:"chat"`
+	Members []ChatMember `json:"members"`
+}
+
+// newMember builds a chat member of the given status
+func newMember(status string, user User) (ChatMember, error) {
+	switch status {
+	case MemberStatusCreator:
+		return &ChatMemberOwner{Status: status, User: user}, nil
+	case MemberStatusAdministrator:
+		return &ChatMemberAdministrator{Status: status, User: user, CanManageChat: true,
+			CanDeleteMessages: true, CanPostMessages: true, CanEditMessages: true}, nil
+	case MemberStatusMember, "":
+		return &ChatMemberMember{Status: MemberStatusMember, User: user}, nil
+	case MemberStatusLeft:
+		return &ChatMemberLeft{Status: status, User: user}, nil
+	case MemberStatusBanned:
+		return &ChatMemberBanned{Status: status, User: user}, nil
+	}
+	return nil, fmt.Errorf("telemock: unsupported member status %q", status)
+}
+
+// withStatus fills in the Status field callers may leave empty
+func withStatus(m ChatMember) ChatMember {
+	switch m := m.(type) {
+	case *ChatMemberOwner:
+		c := *m
+		c.Status = MemberStatusCreator
+		return &c
+	case *ChatMemberAdministrator:
+		c := *m
+		c.Status = MemberStatusAdministrator
+		return &c
+	case *ChatMemberMember:
+		c := *m
+		c.Status = MemberStatusMember
+		return &c
+	case *ChatMemberLeft:
+		c := *m
+		c.Status = MemberStatusLeft
+		return &c
+	case *ChatMemberBanned:
+		c := *m
+		c.Status = MemberStatusBanned
+		return &c
+	}
+	return m
+}
+
+func isAdmin(m ChatMember) bool {
+	s := m.MemberStatus()
+	return s == MemberStatusCreator || s == MemberStatusAdministrator
+}
+
+// CreateChat registers a group, supergroup or channel. Group and channel IDs
+// are negative, like Telegram's. The bot joins groups as a member and
+// channels as an administrator unless members list it otherwise; nobody is
+// told about the members given here.
+func (b *Bot) CreateChat(chat Chat, members ...ChatMember) error {
+	switch {
+	case chat.Type != ChatTypeGroup && chat.Type != ChatTypeSupergroup && chat.Type != ChatTypeChannel:
+		return fmt.Errorf("telemock: can't create a chat of type %q", chat.Type)
+	case chat.ID >= 0:
+		return errors.New("telemock: group and channel IDs must be negative")
+	case chat.Title == "":
+		return errors.New("telemock: chat title is empty")
+	}
+	list := make([]ChatMember, 0, len(members)+1)
+	hasBot := false
+	for _, m := range members {
+		if m == nil {
+			return errors.New("telemock: nil chat member")
+		}
+		hasBot = hasBot || m.MemberUser().ID == b.me.ID
+		list = append(list, withUser(withStatus(m), b.profile(m.MemberUser())))
+	}
+	if !hasBot {
+		status := MemberStatusMember
+		if chat.Type == ChatTypeChannel {
+			status = MemberStatusAdministrator
+		}
+		m, _ := newMember(status, b.me)
+		list = append(list, m)
+	}
+	if !b.store.createChat(chat, list) {
+		return fmt.Errorf("telemock: chat %d already exists", chat.ID)
+	}
+	return b.announceChat(chat.ID)
+}
+
+// AddChatMember makes user join a group or channel as a member. In groups the
+// bot receives a new_chat_members service message, sent by the user.
+func (b *Bot) AddChatMember(chatID int64, user User) error {
+	chat, ok := b.store.chat(chatID)
+	if !ok {
+		return errBadRequest("chat not found")
+	}
+	user = b.profile(user)
+	if old, _ := b.store.member(chatID, user.ID); old != nil && old.MemberIsMember() {
+		return errBadRequest("USER_ALREADY_PARTICIPANT")
+	}
+	m, _ := newMember(MemberStatusMember, user)
+	b.store.setMember(chatID, m)
+	if err := b.announceChat(chatID); err != nil {
+		return err
+	}
+	if chat.Type == ChatTypeChannel {
+		return nil
+	}
+	return b.serviceMessage(chat, user, func(msg *Message) { msg.NewChatMembers = []User{user} })
+}
+
+// RemoveChatMember makes a member leave a group or channel. In groups the bot
+// receives a left_chat_member service message.
+func (b *Bot) RemoveChatMember(chatID, userID int64) error {
+	chat, ok := b.store.chat(chatID)
+	if !ok {
+		return errBadRequest("chat not found")
+	}
+	old, _ := b.store.member(chatID, userID)
+	if old == nil || !old.MemberIsMember() {
+		return errBadRequest("USER_NOT_PARTICIPANT")
+	}
+	user := old.MemberUser()
+	m, _ := newMember(MemberStatusLeft, user)
+	b.store.setMember(chatID, m)
+	if err := b.announceChat(chatID); err != nil {
+		return err
+	}
+	if chat.Type == ChatTypeChannel {
+		return nil
+	}
+	return b.serviceMessage(chat, user, func(msg *Message) { msg.LeftChatMember = &user })
+}
+
+// SetChatMember sets a member's status without any service message, e.g. to
+// promote a member to administrator or to ban one.
+func (b *Bot) SetChatMember(chatID int64, member ChatMember) error {
+	if member == nil {
+		return errors.New("telemock: nil chat member")
+	}
+	member = withUser(withStatus(member), b.profile(member.MemberUser()))
+	if _, ok := b.store.setMember(chatID, member); !ok {
+		return errBadRequest("chat not found")
+	}
+	return b.announceChat(chatID)
+}
+
+// serviceMessage delivers a service message from user to the bot
+func (b *Bot) serviceMessage(chat Chat, user User, fill func(*Message)) error {
+	msg := &Message{MessageID: b.freeMessageID(chat.ID), Date: b.now().Unix(), Chat: chat, From: &user}
+	fill(msg)
+	return b.Inject(Update{Message: msg})
+}
+
+// announceChat sends the chat's members to websocket clients following it
+func (b *Bot) announceChat(chatID int64) error {
+	chat, _ := b.store.chat(chatID)
+	return b.broadcastWS(Event{Type: EventChat, Members: &ChatMembers{Chat: chat, Members: b.store.members(chatID)}})
+}
+
+// chat returns the chat with id: a registered group or channel, or the
+// private chat of the user with that ID, named after the user's profile
+func (b *Bot) chat(id int64) (Chat, error) {
+	if chat, ok := b.store.chat(id); ok {
+		return chat, nil
+	}
+	if id <= 0 {
+		return Chat{}, errBadRequest("chat not found")
+	}
+	u := b.user(id)
+	return Chat{ID: id, Type: ChatTypePrivate, FirstName: u.FirstName, LastName: u.LastName, Username: u.Username}, nil
+}
+
+// sender returns who a client writes as: the chat's user in a private chat,
+// otherwise the member fromID. Only administrators post in channels.
+func (b *Bot) sender(chat Chat, fromID int64, post bool) (User, error) {
+	if chat.Type == ChatTypePrivate {
+		if fromID != 0 && fromID != chat.ID {
+			return User{}, errBadRequest("can't write to a private chat as another user")
+		}
+		return b.user(chat.ID), nil
+	}
+	if fromID == 0 {
+		return User{}, errBadRequest("sender is not specified")
+	}
+	m, _ := b.store.member(chat.ID, fromID)
+	if m == nil || !m.MemberIsMember() {
+		return User{}, errForbidden("user is not a member of the " + chat.Type + " chat")
+	}
+	if post && chat.Type == ChatTypeChannel && !isAdmin(m) {
+		return User{}, errForbidden("only administrators can post in the channel chat")
+	}
+	return m.MemberUser(), nil
+}
+
+// canPost checks that the bot may send messages to chat
+func (b *Bot) canPost(chat Chat) error {
+	if chat.Type == ChatTypePrivate {
+		return nil
+	}
+	m, _ := b.store.member(chat.ID, b.me.ID)
+	if m == nil || !m.MemberIsMember() {
+		return errForbidden("bot is not a member of the " + chat.Type + " chat")
+	}
+	if chat.Type == ChatTypeChannel && !isAdmin(m) {
+		return errBadRequest("need administrator rights in the channel chat")
+	}
+	return nil
+}
+
+// GetChatMember returns a user's membership in a chat. Users who never joined
+// a group are reported as having left it.
+func (b *Bot) GetChatMember(ctx context.Context, params *GetChatMemberParams) (ChatMember, error) {
+	if params == nil {
+		return nil, errors.New("nil params")
+	}
+	chat, err := b.chat(params.ChatID.ID)
+	if err != nil {
+		return nil, err
+	}
+	if chat.Type == ChatTypePrivate {
+		switch params.UserID {
+		case b.me.ID:
+			return newMember(MemberStatusMember, b.me)
+		case chat.ID:
+			return newMember(MemberStatusMember, b.user(chat.ID))
+		}
+		return nil, errBadRequest("user not found")
+	}
+	if m, ok := b.store.member(chat.ID, params.UserID); ok {
+		return m, nil
+	}
+	return newMember(MemberStatusLeft, b.user(params.UserID))
+}
+
+// GetChatAdministrators returns the creator and administrators of a group or channel.
+func (b *Bot) GetChatAdministrators(ctx context.Context, params *GetChatAdministratorsParams) ([]ChatMember, error) {
+	if params == nil {
+		return nil, errors.New("nil params")
+	}
+	chat, err := b.chat(params.ChatID.ID)
+	if err != nil {
+		return nil, err
+	}
+	if chat.Type == ChatTypePrivate {
+		return nil, errBadRequest("there are no administrators in the private chat")
+	}
+	admins := []ChatMember{}
+	for _, m := range b.store.members(chat.ID) {
+		if isAdmin(m) {
+			admins = append(admins, m)
+		}
+	}
+	return admins, nil
+}
+
+// GetChatMemberCount returns how many members a chat has, the bot included.
+func (b *Bot) GetChatMemberCount(ctx context.Context, params *GetChatMemberCountParams) (*int, error) {
+	if params == nil {
+		return nil, errors.New("nil params")
+	}
+	chat, err := b.chat(params.ChatID.ID)
+	if err != nil {
+		return nil, err
+	}
+	n := 2
+	if chat.Type != ChatTypePrivate {
+		n = 0
+		for _, m := range b.store.members(chat.ID) {
+			if m.MemberIsMember() {
+				n++
+			}
+		}
+	}
+	return &n, nil
+}
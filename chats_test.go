@@ -0,0 +1,191 @@
+package telemock
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupChat(t *testing.T) {
+	t.Parallel()
+	bot, err := NewBot("token", WithoutListener(), WithoutLogFile(), WithLogger(nil))
+	require.NoError(t, err)
+	defer bot.Close(context.Background())
+	ctx := context.Background()
+
+	alice, bobby, carol := User{ID: 1, FirstName: "Alice"}, User{ID: 2, FirstName: "Bob"}, User{ID: 3, FirstName: "Carol"}
+	group := Chat{ID: -100, Type: ChatTypeSupergroup, Title: "Team"}
+	require.NoError(t, bot.CreateChat(group, &ChatMemberOwner{User: alice}, &ChatMemberMember{User: bobby}))
+	require.Error(t, bot.CreateChat(group))
+	require.Error(t, bot.CreateChat(Chat{ID: 5, Type: ChatTypeGroup, Title: "positive"}))
+
+	msg, err := bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: group.ID}, Text: "hi all"})
+	require.NoError(t, err)
+	require.Equal(t, group, msg.Chat)
+	require.Equal(t, bot.me.ID, msg.From.ID)
+
+	// members write as themselves, others can't write at all
+	upd, err := bot.textUpdate(group.ID, bobby.ID, 0, "/help")
+	require.NoError(t, err)
+	require.Equal(t, bobby, *upd.Message.From)
+	require.Equal(t, ChatTypeSupergroup, upd.Message.Chat.Type)
+	_, err = bot.textUpdate(group.ID, carol.ID, 0, "hey")
+	require.EqualError(t, err, `telemock: api: 403 "Forbidden: user is not a member of the supergroup chat"`)
+	_, err = bot.textUpdate(group.ID, 0, 0, "hey")
+	require.Error(t, err)
+
+	owner, err := bot.GetChatMember(ctx, &GetChatMemberParams{ChatID: ChatID{ID: group.ID}, UserID: alice.ID})
+	require.NoError(t, err)
+	require.Equal(t, MemberStatusCreator, owner.MemberStatus())
+	admins, err := bot.GetChatAdministrators(ctx, &GetChatAdministratorsParams{ChatID: ChatID{ID: group.ID}})
+	require.NoError(t, err)
+	require.Equal(t, []ChatMember{&ChatMemberOwner{Status: MemberStatusCreator, User: alice}}, admins)
+
+	// joining and leaving reach the bot as service messages
+	require.NoError(t, bot.AddChatMember(group.ID, carol))
+	joined := <-bot.updates
+	require.Equal(t, []User{carol}, joined.Message.NewChatMembers)
+	require.Equal(t, carol, *joined.Message.From)
+	count, err := bot.GetChatMemberCount(ctx, &GetChatMemberCountParams{ChatID: ChatID{ID: group.ID}})
+	require.NoError(t, err)
+	require.Equal(t, 4, *count)
+
+	require.NoError(t, bot.RemoveChatMember(group.ID, carol.ID))
+	left := <-bot.updates
+	require.Equal(t, carol, *left.Message.LeftChatMember)
+	gone, err := bot.GetChatMember(ctx, &GetChatMemberParams{ChatID: ChatID{ID: group.ID}, UserID: carol.ID})
+	require.NoError(t, err)
+	require.False(t, gone.MemberIsMember())
+
+	// a bot that was removed can't write
+	require.NoError(t, bot.SetChatMember(group.ID, &ChatMemberBanned{User: bot.me}))
+	_, err = bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: group.ID}, Text: "still here?"})
+	require.EqualError(t, err, `telemock: api: 403 "Forbidden: bot is not a member of the supergroup chat"`)
+
+	_, err = bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: -5}, Text: "nowhere"})
+	require.EqualError(t, err, `telemock: api: 400 "Bad Request: chat not found"`)
+}
+
+func TestChannelChat(t *testing.T) {
+	t.Parallel()
+	bot, err := NewBot("token", WithoutListener(), WithoutLogFile(), WithLogger(nil))
+	require.NoError(t, err)
+	defer bot.Close(context.Background())
+	ctx := context.Background()
+
+	channel := Chat{ID: -200, Type: ChatTypeChannel, Title: "News"}
+	require.NoError(t, bot.CreateChat(channel, &ChatMemberOwner{User: User{ID: 1}}, &ChatMemberMember{User: User{ID: 2}}))
+
+	// the bot posts on behalf of the channel and may edit the post
+	msg, err := bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: channel.ID}, Text: "news"})
+	require.NoError(t, err)
+	require.Nil(t, msg.From)
+	require.Equal(t, channel, *msg.SenderChat)
+	_, err = bot.EditMessageText(ctx, &EditMessageTextParams{ChatID: ChatID{ID: channel.ID}, MessageID: msg.MessageID, Text: "more news"})
+	require.NoError(t, err)
+
+	// administrators post, subscribers only press buttons
+	upd, err := bot.textUpdate(channel.ID, 1, 0, "hello")
+	require.NoError(t, err)
+	require.Nil(t, upd.Message)
+	require.Equal(t, channel, *upd.ChannelPost.SenderChat)
+	_, err = bot.textUpdate(channel.ID, 2, 0, "hello")
+	require.Error(t, err)
+	_, err = bot.callbackUpdate(channel.ID, 2, msg.MessageID, "", "like")
+	require.NoError(t, err)
+
+	require.NoError(t, bot.SetChatMember(channel.ID, &ChatMemberMember{User: bot.me}))
+	_, err = bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: channel.ID}, Text: "news"})
+	require.EqualError(t, err, `telemock: api: 400 "Bad Request: need administrator rights in the channel chat"`)
+}
+
+func TestGroupChat_Frames(t *testing.T) {
+	t.Parallel()
+	bot := newTestBot(t)
+	defer bot.Close(context.Background())
+	conn, _, err := websocket.DefaultDialer.Dial(bot.URL()+"?v=1&chat_id=-300", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	waitClients(t, bot, 1)
+
+	updates, err := bot.UpdatesViaLongPolling(context.Background(), &GetUpdatesParams{})
+	require.NoError(t, err)
+
+	send := func(typ string, payload any) envelope {
+		t.Helper()
+		frame, _ := json.Marshal(map[string]any{"v": 1, "type": typ, "id": typ, "payload": payload})
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, frame))
+		return readEnvelope(t, conn)
+	}
+
+	// the server announces the members, then acks
+	env := send("chat", map[string]any{"id": -300, "type": "group", "title": "Friends",
+		"members": []map[string]any{{"user": map[string]any{"id": 7, "first_name": "Ann"}, "status": "creator"}}})
+	require.Equal(t, "chat", env.Type)
+	require.JSONEq(t, `{"chat":{"id":-300,"type":"group","title":"Friends"},"members":[
+		{"status":"creator","user":{"id":7,"is_bot":false,"first_name":"Ann"},"is_anonymous":false},
+		{"status":"member","user":{"id":0,"is_bot":true,"first_name":"Telemock","username":"telemock_bot"}}]}`, string(env.Payload))
+	require.Equal(t, "ack", readEnvelope(t, conn).Type)
+
+	require.Equal(t, "chat", send("join", map[string]any{"chat_id": -300, "user": map[string]any{"id": 8, "first_name": "Ben"}}).Type)
+	require.Equal(t, "ack", readEnvelope(t, conn).Type)
+	require.Equal(t, []User{{ID: 8, FirstName: "Ben"}}, (<-updates).Message.NewChatMembers)
+
+	require.Equal(t, "ack", send("message", map[string]any{"chat_id": -300, "from_id": 8, "text": "hi"}).Type)
+	upd := <-updates
+	require.Equal(t, int64(8), upd.Message.From.ID)
+	require.Equal(t, "Ben", upd.Message.From.FirstName)
+
+	env = send("message", map[string]any{"chat_id": -300, "from_id": 9, "text": "hi"})
+	require.Equal(t, "error", env.Type)
+	require.Contains(t, string(env.Payload), "user is not a member")
+}
+
+func TestUserProfile(t *testing.T) {
+	t.Parallel()
+	bot, err := NewBot("token", WithoutListener(), WithoutLogFile(), WithLogger(nil))
+	require.NoError(t, err)
+	defer bot.Close(context.Background())
+	ctx := context.Background()
+
+	require.Error(t, bot.SetUserProfile(User{ID: 1}))
+	require.Error(t, bot.SetUserProfile(bot.me))
+
+	group := Chat{ID: -100, Type: ChatTypeGroup, Title: "Team"}
+	require.NoError(t, bot.CreateChat(group, &ChatMemberMember{User: User{ID: 1}}))
+	alice := User{ID: 1, FirstName: "Alice", Username: "alice", LanguageCode: "de", IsPremium: true}
+	require.NoError(t, bot.SetUserProfile(alice))
+	profile, ok := bot.UserProfile(1)
+	require.True(t, ok)
+	require.Equal(t, alice, profile)
+
+	// the profile is stamped on everything the user sends, wherever
+	upd, err := bot.textUpdate(1, 0, 0, "hi")
+	require.NoError(t, err)
+	require.Equal(t, alice, *upd.Message.From)
+	require.Equal(t, "Alice", upd.Message.Chat.FirstName)
+	upd, err = bot.textUpdate(group.ID, 1, 0, "hi")
+	require.NoError(t, err)
+	require.Equal(t, alice, *upd.Message.From)
+
+	msg, err := bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: 1}, Text: "menu",
+		ReplyMarkup: &InlineKeyboardMarkup{InlineKeyboard: [][]InlineKeyboardButton{{{Text: "Go", CallbackData: "go"}}}}})
+	require.NoError(t, err)
+	upd, err = bot.callbackUpdate(1, 0, msg.MessageID, "", "go")
+	require.NoError(t, err)
+	require.Equal(t, alice, upd.CallbackQuery.From)
+
+	member, err := bot.GetChatMember(ctx, &GetChatMemberParams{ChatID: ChatID{ID: group.ID}, UserID: 1})
+	require.NoError(t, err)
+	require.Equal(t, alice, member.MemberUser())
+
+	// a user joining later gets the registered profile too
+	require.NoError(t, bot.SetUserProfile(User{ID: 2, FirstName: "Bob", IsBot: true}))
+	require.NoError(t, bot.AddChatMember(group.ID, User{ID: 2}))
+	joined := <-bot.updates
+	require.Equal(t, "Bob", joined.Message.From.FirstName)
+	require.True(t, joined.Message.From.IsBot)
+}
@@ -0,0 +1,192 @@
+package telemock
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// SlowClientPolicy decides what happens when a websocket client's outbound
+// queue is full because the browser tab reads slower than the bot writes.
+type SlowClientPolicy int
+
+const (
+	// SlowClientBlock makes the sender wait until the client catches up or
+	// its connection fails. This is the default.
+	SlowClientBlock SlowClientPolicy = iota
+	// SlowClientDropOldest discards the oldest queued frame.
+	SlowClientDropOldest
+	// SlowClientDisconnect closes the connection of the lagging client.
+	SlowClientDisconnect
+)
+
+const writeTimeout = 2 * time.Second
+
+// client is a websocket connection with its own outbound queue. gorilla/websocket
+// allows only one concurrent writer, so every write goes through writeLoop.
+//
+// A client either observes every chat or only receives bot messages for the
+// chats it subscribed to.
+type client struct {
+	conn *websocket.Conn
+	send chan []byte
+	done chan struct{}
+	once sync.Once
+
+	version atomic.Int32 // websocket protocol version spoken by the client
+
+	subMu    sync.RWMutex
+	observer bool
+	chats    map[int64]struct{}
+
+	// broadcasts held while the client is replayed the history
+	heldMu  sync.Mutex
+	holding bool
+	held    [][]byte
+}
+
+func (c *client) protocol() int {
+	return int(c.version.Load())
+}
+
+func (c *client) setProtocol(v int) {
+	c.version.Store(int32(v))
+}
+
+func newClient(conn *websocket.Conn, queue int) *client {
+	return &client{
+		conn:     conn,
+		send:     make(chan []byte, queue),
+		done:     make(chan struct{}),
+		observer: true,
+		chats:    make(map[int64]struct{}),
+	}
+}
+
+// wants reports whether bot messages for chatID should reach the client
+func (c *client) wants(chatID int64) bool {
+	c.subMu.RLock()
+	defer c.subMu.RUnlock()
+	if c.observer {
+		return true
+	}
+	_, ok := c.chats[chatID]
+	return ok
+}
+
+// subscribe switches the client to per-chat routing and adds chat IDs
+func (c *client) subscribe(ids ...int64) {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	c.observer = false
+	for _, id := range ids {
+		c.chats[id] = struct{}{}
+	}
+}
+
+func (c *client) unsubscribe(ids ...int64) {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	c.observer = false
+	for _, id := range ids {
+		delete(c.chats, id)
+	}
+}
+
+// follow subscribes a routed client to a chat it writes in; observers are unaffected
+func (c *client) follow(chatID int64) {
+	c.subMu.Lock()
+	defer c.subMu.Unlock()
+	if !c.observer {
+		c.chats[chatID] = struct{}{}
+	}
+}
+
+// setObserver makes the client receive every chat regardless of subscriptions
+func (c *client) setObserver() {
+	c.subMu.Lock()
+	c.observer = true
+	c.subMu.Unlock()
+}
+
+// hold keeps a broadcast frame back while the history is replayed. It
+// reports false once the client takes frames directly.
+func (c *client) hold(data []byte) bool {
+	c.heldMu.Lock()
+	defer c.heldMu.Unlock()
+	if c.holding {
+		c.held = append(c.held, data)
+	}
+	return c.holding
+}
+
+// takeHeld returns the held frames; when none are left it stops holding
+func (c *client) takeHeld() [][]byte {
+	c.heldMu.Lock()
+	defer c.heldMu.Unlock()
+	held := c.held
+	c.held = nil
+	c.holding = len(held) > 0
+	return held
+}
+
+// stop closes the connection and ends writeLoop; it is safe to call repeatedly.
+func (c *client) stop() {
+	c.once.Do(func() {
+		close(c.done)
+		_ = c.conn.Close()
+	})
+}
+
+// enqueue queues a frame according to policy. It reports false when the
+// client should be disconnected.
+func (c *client) enqueue(data []byte, policy SlowClientPolicy) bool {
+	select {
+	case c.send <- data:
+		return true
+	case <-c.done:
+		return true
+	default:
+	}
+
+	switch policy {
+	case SlowClientDropOldest:
+		select {
+		case <-c.send:
+		default:
+		}
+		// if a concurrent sender refilled the slot, this frame is the one dropped
+		select {
+		case c.send <- data:
+		default:
+		}
+		return true
+	case SlowClientDisconnect:
+		return false
+	default:
+		select {
+		case c.send <- data:
+		case <-c.done:
+		}
+		return true
+	}
+}
+
+// writeLoop is the only goroutine writing to the connection.
+func (b *Bot) writeLoop(c *client) {
+	for {
+		select {
+		case data := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				b.logger.Printf("telemock: write error: %v\n", err)
+				b.removeClient(c)
+				return
+			}
+		case <-c.done:
+			return
+		}
+	}
+}
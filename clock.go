@@ -0,0 +1,119 @@
+package telemock
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// Clock tells telemock what time it is. Message dates, edit dates, the
+// deadlines for answering callback queries and deleting messages, and the
+// getUpdates long polling timeout all come from the bot's clock, which is the
+// wall clock unless WithClock replaces it.
+type Clock interface {
+	Now() time.Time
+	// NewTimer starts a timer that fires once d has passed on the clock.
+	NewTimer(d time.Duration) Timer
+}
+
+// Timer is a timer started by a Clock.
+type Timer interface {
+	// C receives the time once the timer fires.
+	C() <-chan time.Time
+	// Stop prevents the timer from firing. It reports false if the timer
+	// already fired or was stopped.
+	Stop() bool
+}
+
+type wallClock struct{}
+
+func (wallClock) Now() time.Time { return time.Now() }
+
+func (wallClock) NewTimer(d time.Duration) Timer { return wallTimer{time.NewTimer(d)} }
+
+type wallTimer struct{ t *time.Timer }
+
+func (t wallTimer) C() <-chan time.Time { return t.t.C }
+
+func (t wallTimer) Stop() bool { return t.t.Stop() }
+
+// MockClock is a Clock that stands still until a test moves it, so scenarios
+// that depend on time run deterministically:
+//
+//	clock := telemock.NewMockClock(time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC))
+//	bot, _ := telemock.NewBot(token, telemock.WithClock(clock))
+//	clock.Advance(49 * time.Hour) // the bot's old messages can't be deleted now
+type MockClock struct {
+	mu     sync.Mutex
+	now    time.Time
+	timers []*mockTimer // timers that haven't fired yet
+}
+
+// NewMockClock returns a clock stopped at t.
+func NewMockClock(t time.Time) *MockClock {
+	return &MockClock{now: t}
+}
+
+// Now returns the clock's current time.
+func (c *MockClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTimer starts a timer that fires when Advance or Set move the clock d
+// past the current time.
+func (c *MockClock) NewTimer(d time.Duration) Timer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	t := &mockTimer{clock: c, at: c.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		t.c <- c.now
+		return t
+	}
+	c.timers = append(c.timers, t)
+	return t
+}
+
+// Advance moves the clock forward by d.
+func (c *MockClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+	c.fire()
+}
+
+// Set moves the clock to t, which may also be in the past.
+func (c *MockClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+	c.fire()
+}
+
+// fire sends the time to the timers that are due; c.mu must be held
+func (c *MockClock) fire() {
+	c.timers = slices.DeleteFunc(c.timers, func(t *mockTimer) bool {
+		if t.at.After(c.now) {
+			return false
+		}
+		t.c <- c.now
+		return true
+	})
+}
+
+type mockTimer struct {
+	clock *MockClock
+	at    time.Time
+	c     chan time.Time
+}
+
+func (t *mockTimer) C() <-chan time.Time { return t.c }
+
+func (t *mockTimer) Stop() bool {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+	n := len(t.clock.timers)
+	t.clock.timers = slices.DeleteFunc(t.clock.timers, func(o *mockTimer) bool { return o == t })
+	return len(t.clock.timers) < n
+}
@@ -0,0 +1,101 @@
+package telemock
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockClock(t *testing.T) {
+	t.Parallel()
+	start := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC)
+	clock := NewMockClock(start)
+	bot, err := NewBot("token", WithoutListener(), WithoutLogFile(), WithLogger(nil), WithClock(clock))
+	require.NoError(t, err)
+	defer bot.Close(context.Background())
+	ctx := context.Background()
+	_, err = NewBot("token", WithoutListener(), WithClock(nil))
+	require.Error(t, err)
+
+	// dates come from the clock
+	msg, err := bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: 1}, Text: "hi",
+		ReplyMarkup: &InlineKeyboardMarkup{InlineKeyboard: [][]InlineKeyboardButton{{{Text: "Go", CallbackData: "go"}}}}})
+	require.NoError(t, err)
+	require.Equal(t, start.Unix(), msg.Date)
+	upd, err := bot.textUpdate(1, 0, 0, "hello")
+	require.NoError(t, err)
+	require.Equal(t, start.Unix(), upd.Message.Date)
+
+	clock.Advance(time.Minute)
+	edited, err := bot.EditMessageText(ctx, &EditMessageTextParams{ChatID: ChatID{ID: 1}, MessageID: msg.MessageID, Text: "hi!"})
+	require.NoError(t, err)
+	require.Equal(t, start.Add(time.Minute).Unix(), edited.EditDate)
+	require.NoError(t, bot.Inject(upd))
+	userEdit, err := bot.editUpdate(1, 0, upd.Message.MessageID, "hello!")
+	require.NoError(t, err)
+	require.Equal(t, start.Unix(), userEdit.EditedMessage.Date)
+	require.Equal(t, start.Add(time.Minute).Unix(), userEdit.EditedMessage.EditDate)
+
+	// callback query IDs are deterministic and expire by the clock
+	press, err := bot.callbackUpdate(1, 0, msg.MessageID, "", "go")
+	require.NoError(t, err)
+	require.Equal(t, "cb-1", press.CallbackQuery.ID)
+	require.NoError(t, bot.Inject(press))
+	clock.Advance(defaultCallbackTimeout + time.Second)
+	err = bot.AnswerCallbackQuery(ctx, &AnswerCallbackQueryParams{CallbackQueryID: press.CallbackQuery.ID})
+	require.ErrorIs(t, err, errQueryTooOld)
+
+	// two days later the message can't be deleted, unless time is set back
+	clock.Set(start.Add(49 * time.Hour))
+	err = bot.DeleteMessage(ctx, &DeleteMessageParams{ChatID: ChatID{ID: 1}, MessageID: msg.MessageID})
+	require.EqualError(t, err, `telemock: api: 400 "Bad Request: message can't be deleted"`)
+	clock.Set(start.Add(time.Hour))
+	require.NoError(t, bot.DeleteMessage(ctx, &DeleteMessageParams{ChatID: ChatID{ID: 1}, MessageID: msg.MessageID}))
+}
+
+func TestMockClock_GetUpdatesTimeout(t *testing.T) {
+	t.Parallel()
+	clock := NewMockClock(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC))
+	bot, err := NewBot("token", WithoutListener(), WithoutLogFile(), WithLogger(nil), WithClock(clock))
+	require.NoError(t, err)
+	defer bot.Close(context.Background())
+
+	type result struct {
+		updates []Update
+		err     error
+	}
+	done := make(chan result, 1)
+	go func() {
+		updates, err := bot.getUpdates(context.Background(), &GetUpdatesParams{Timeout: 30})
+		done <- result{updates, err}
+	}()
+	// long polling waits on the mock clock, not on real time
+	require.Eventually(t, func() bool {
+		clock.mu.Lock()
+		defer clock.mu.Unlock()
+		return len(clock.timers) == 1
+	}, 2*time.Second, time.Millisecond)
+	clock.Advance(29 * time.Second)
+	select {
+	case <-done:
+		t.Fatal("getUpdates returned before the timeout")
+	case <-time.After(20 * time.Millisecond):
+	}
+	clock.Advance(2 * time.Second)
+	select {
+	case res := <-done:
+		require.NoError(t, res.err)
+		require.Empty(t, res.updates)
+	case <-time.After(2 * time.Second):
+		t.Fatal("getUpdates did not time out on the mock clock")
+	}
+
+	// a stopped timer never fires
+	timer := clock.NewTimer(time.Second)
+	require.True(t, timer.Stop())
+	require.False(t, timer.Stop())
+	clock.Advance(time.Minute)
+	require.Empty(t, timer.C())
+}
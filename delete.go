@@ -0,0 +1,81 @@
+package telemock
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+type DeleteMessageParams struct {
+	ChatID    ChatID `json:"chat_id"`
+	MessageID int    `json:"message_id"`
+}
+
+type DeleteMessagesParams struct {
+	ChatID     ChatID `json:"chat_id"`
+	MessageIDs []int  `json:"message_ids"`
+}
+
+// deleteWindow is how long after sending a message can still be deleted
+const deleteWindow = 48 * time.Hour
+
+// maxDeleteMessages is the limit on message_ids in one DeleteMessages call
+const maxDeleteMessages = 100
+
+// DeleteMessage removes a message from its chat and tells the chat's clients to
+// drop it. Messages older than 48 hours can't be deleted.
+func (b *Bot) DeleteMessage(ctx context.Context, params *DeleteMessageParams) error {
+	if params == nil {
+		return errors.New("nil params")
+	}
+	if params.MessageID == 0 {
+		return errBadRequest("message identifier is not specified")
+	}
+	return b.deleteMessages(params.ChatID, []int{params.MessageID})
+}
+
+// DeleteMessages removes several messages of one chat at once. Like in
+// Telegram, messages that are missing or can't be deleted are skipped; the call
+// fails only when none of them was deleted.
+func (b *Bot) DeleteMessages(ctx context.Context, params *DeleteMessagesParams) error {
+	if params == nil {
+		return errors.New("nil params")
+	}
+	if len(params.MessageIDs) == 0 {
+		return errBadRequest("message identifiers are not specified")
+	}
+	if len(params.MessageIDs) > maxDeleteMessages {
+		return errBadRequest("too many messages to delete")
+	}
+	return b.deleteMessages(params.ChatID, params.MessageIDs)
+}
+
+// deleteMessages removes ids from the store and publishes an EventDelete for
+// the ones that were removed. In groups and channels the bot must be allowed to
+// post there.
+func (b *Bot) deleteMessages(chatID ChatID, ids []int) error {
+	if b.isClosed() {
+		return ErrBotClosed
+	}
+	chat, err := b.chat(chatID.ID)
+	if err != nil {
+		return err
+	}
+	if err := b.canPost(chat); err != nil {
+		return err
+	}
+	now := b.now()
+	removed, err := b.store.remove(chatID.ID, ids, func(m Message) error {
+		if now.Sub(time.Unix(m.Date, 0)) > deleteWindow {
+			return errBadRequest("message can't be deleted")
+		}
+		return nil
+	})
+	if len(removed) == 0 {
+		if err != nil {
+			return err
+		}
+		return errBadRequest("message to delete not found")
+	}
+	return b.publish(Event{Type: EventDelete, Deleted: &Deleted{ChatID: chatID.ID, MessageIDs: removed}})
+}
@@ -0,0 +1,102 @@
+package telemock
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeleteMessage(t *testing.T) {
+	t.Parallel()
+	bot := newTestBot(t)
+	defer bot.Close(context.Background())
+	conn := dialWS(t, bot)
+	defer conn.Close()
+	waitClients(t, bot, 1)
+	ctx := context.Background()
+
+	sent, err := bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: 5}, Text: "prompt"})
+	require.NoError(t, err)
+	require.NoError(t, bot.DeleteMessage(ctx, &DeleteMessageParams{ChatID: ChatID{ID: 5}, MessageID: sent.MessageID}))
+
+	err = bot.DeleteMessage(ctx, &DeleteMessageParams{ChatID: ChatID{ID: 5}, MessageID: sent.MessageID})
+	require.EqualError(t, err, `telemock: api: 400 "Bad Request: message to delete not found"`)
+	_, err = bot.EditMessageText(ctx, &EditMessageTextParams{ChatID: ChatID{ID: 5}, MessageID: sent.MessageID, Text: "x"})
+	require.EqualError(t, err, `telemock: api: 400 "Bad Request: message to edit not found"`)
+
+	var frames []outboundPayload
+	for i := 0; i < 2; i++ {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, raw, err := conn.ReadMessage()
+		require.NoError(t, err)
+		var out outboundPayload
+		require.NoError(t, json.Unmarshal(raw, &out))
+		frames = append(frames, out)
+	}
+	require.Equal(t, "delete", frames[1].Event)
+	require.EqualValues(t, 5, frames[1].ChatID)
+	require.Equal(t, []int{sent.MessageID}, frames[1].MessageIDs)
+}
+
+func TestDeleteMessages(t *testing.T) {
+	t.Parallel()
+	bot, err := NewBot("token", WithoutListener(), WithoutLogFile(), WithLogger(nil))
+	require.NoError(t, err)
+	defer bot.Close(context.Background())
+	ctx := context.Background()
+
+	var ids []int
+	for _, text := range []string{"a", "b", "c"} {
+		m, err := bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: 6}, Text: text})
+		require.NoError(t, err)
+		ids = append(ids, m.MessageID)
+		<-bot.Outbox()
+	}
+	// a message sent more than 48 hours ago stays
+	old := Message{MessageID: 100, Date: time.Now().Add(-49 * time.Hour).Unix(), Chat: Chat{ID: 6}, Text: "old"}
+	bot.store.put(old, true)
+
+	err = bot.DeleteMessage(ctx, &DeleteMessageParams{ChatID: ChatID{ID: 6}, MessageID: 100})
+	require.EqualError(t, err, `telemock: api: 400 "Bad Request: message can't be deleted"`)
+
+	// missing and undeletable IDs are skipped
+	require.NoError(t, bot.DeleteMessages(ctx, &DeleteMessagesParams{ChatID: ChatID{ID: 6}, MessageIDs: []int{ids[0], 999, 100, ids[2]}}))
+	ev := <-bot.Outbox()
+	require.Equal(t, EventDelete, ev.Type)
+	require.Equal(t, &Deleted{ChatID: 6, MessageIDs: []int{ids[0], ids[2]}}, ev.Deleted)
+
+	_, ok := bot.store.get(6, ids[1])
+	require.True(t, ok)
+	_, ok = bot.store.get(6, 100)
+	require.True(t, ok)
+
+	err = bot.DeleteMessages(ctx, &DeleteMessagesParams{ChatID: ChatID{ID: 6}, MessageIDs: []int{ids[0]}})
+	require.EqualError(t, err, `telemock: api: 400 "Bad Request: message to delete not found"`)
+	err = bot.DeleteMessages(ctx, &DeleteMessagesParams{ChatID: ChatID{ID: 6}})
+	require.EqualError(t, err, `telemock: api: 400 "Bad Request: message identifiers are not specified"`)
+}
+
+func TestDeleteMessage_BotLeftGroup(t *testing.T) {
+	t.Parallel()
+	bot, err := NewBot("token", WithoutListener(), WithoutLogFile(), WithLogger(nil))
+	require.NoError(t, err)
+	defer bot.Close(context.Background())
+	ctx := context.Background()
+
+	group := Chat{ID: -400, Type: ChatTypeGroup, Title: "Team"}
+	require.NoError(t, bot.CreateChat(group, &ChatMemberMember{User: bot.me}))
+	sent, err := bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: group.ID}, Text: "hi"})
+	require.NoError(t, err)
+	<-bot.Outbox()
+
+	require.NoError(t, bot.SetChatMember(group.ID, &ChatMemberLeft{Status: MemberStatusLeft, User: bot.me}))
+	err = bot.DeleteMessage(ctx, &DeleteMessageParams{ChatID: ChatID{ID: group.ID}, MessageID: sent.MessageID})
+	require.EqualError(t, err, `telemock: api: 403 "Forbidden: bot is not a member of the group chat"`)
+	err = bot.DeleteMessages(ctx, &DeleteMessagesParams{ChatID: ChatID{ID: group.ID}, MessageIDs: []int{sent.MessageID}})
+	require.EqualError(t, err, `telemock: api: 403 "Forbidden: bot is not a member of the group chat"`)
+	_, ok := bot.store.get(group.ID, sent.MessageID)
+	require.True(t, ok)
+}
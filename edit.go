@@ -0,0 +1,170 @@
+package telemock
+
+import (
+	"context"
+	"errors"
+	"reflect"
+)
+
+type EditMessageTextParams struct {
+	BusinessConnectionID string                `json:"business_connection_id,omitempty"`
+	ChatID               ChatID                `json:"chat_id,omitempty"`
+	MessageID            int                   `json:"message_id,omitempty"`
+	InlineMessageID      string                `json:"inline_message_id,omitempty"`
+	Text                 string                `json:"text"`
+	ParseMode            string                `json:"parse_mode,omitempty"`
+	Entities             []MessageEntity       `json:"entities,omitempty"`
+	LinkPreviewOptions   *LinkPreviewOptions   `json:"link_preview_options,omitempty"`
+	ReplyMarkup          *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+type EditMessageReplyMarkupParams struct {
+	BusinessConnectionID string                `json:"business_connection_id,omitempty"`
+	ChatID               ChatID                `json:"chat_id,omitempty"`
+	MessageID            int                   `json:"message_id,omitempty"`
+	InlineMessageID      string                `json:"inline_message_id,omitempty"`
+	ReplyMarkup          *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+type EditMessageCaptionParams struct {
+	BusinessConnectionID  string                `json:"business_connection_id,omitempty"`
+	ChatID                ChatID                `json:"chat_id,omitempty"`
+	MessageID             int                   `json:"message_id,omitempty"`
+	InlineMessageID       string                `json:"inline_message_id,omitempty"`
+	Caption               string                `json:"caption,omitempty"`
+	ParseMode             string                `json:"parse_mode,omitempty"`
+	CaptionEntities       []MessageEntity       `json:"caption_entities,omitempty"`
+	ShowCaptionAboveMedia bool                  `json:"show_caption_above_media,omitempty"`
+	ReplyMarkup           *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+var errNotModified = errBadRequest("message is not modified: specified new message content and reply markup " +
+	"are exactly the same as a current content and reply markup of the message")
+
+// EditMessageText replaces the text of a bot message. As in Telegram, omitting
+// ReplyMarkup removes the message's inline keyboard.
+func (b *Bot) EditMessageText(ctx context.Context, params *EditMessageTextParams) (*Message, error) {
+	if params == nil {
+		return nil, errors.New("nil params")
+	}
+	if params.Text == "" {
+		return nil, errBadRequest("message text is empty")
+	}
+	if err := validateInlineKeyboard(params.ReplyMarkup); err != nil {
+		return nil, err
+	}
+	return b.editMessage(params.ChatID, params.MessageID, params.InlineMessageID, func(m *Message) error {
+		if m.Text == "" {
+			return errBadRequest("there is no text in the message to edit")
+		}
+		markup := normalizeMarkup(params.ReplyMarkup)
+		if m.Text == params.Text && entitiesEqual(m.Entities, params.Entities) && markupEqual(m.ReplyMarkup, markup) {
+			return errNotModified
+		}
+		m.Text, m.Entities, m.ReplyMarkup = params.Text, params.Entities, markup
+		return nil
+	})
+}
+
+// EditMessageReplyMarkup replaces the inline keyboard of a bot message; a nil
+// ReplyMarkup removes it.
+func (b *Bot) EditMessageReplyMarkup(ctx context.Context, params *EditMessageReplyMarkupParams) (*Message, error) {
+	if params == nil {
+		return nil, errors.New("nil params")
+	}
+	if err := validateInlineKeyboard(params.ReplyMarkup); err != nil {
+		return nil, err
+	}
+	return b.editMessage(params.ChatID, params.MessageID, params.InlineMessageID, func(m *Message) error {
+		markup := normalizeMarkup(params.ReplyMarkup)
+		if markupEqual(m.ReplyMarkup, markup) {
+			return errNotModified
+		}
+		m.ReplyMarkup = markup
+		return nil
+	})
+}
+
+// EditMessageCaption replaces the caption of a bot media message.
+func (b *Bot) EditMessageCaption(ctx context.Context, params *EditMessageCaptionParams) (*Message, error) {
+	if params == nil {
+		return nil, errors.New("nil params")
+	}
+	if err := validateInlineKeyboard(params.ReplyMarkup); err != nil {
+		return nil, err
+	}
+	return b.editMessage(params.ChatID, params.MessageID, params.InlineMessageID, func(m *Message) error {
+		if m.Text != "" {
+			return errBadRequest("there is no caption in the message to edit")
+		}
+		markup := normalizeMarkup(params.ReplyMarkup)
+		if m.Caption == params.Caption && entitiesEqual(m.CaptionEntities, params.CaptionEntities) &&
+			markupEqual(m.ReplyMarkup, markup) {
+			return errNotModified
+		}
+		m.Caption, m.CaptionEntities, m.ReplyMarkup = params.Caption, params.CaptionEntities, markup
+		return nil
+	})
+}
+
+// editMessage applies fn to a stored bot message and publishes the result as
+// an EventEdit
+func (b *Bot) editMessage(chatID ChatID, msgID int, inlineID string, fn func(*Message) error) (*Message, error) {
+	if b.isClosed() {
+		return nil, ErrBotClosed
+	}
+	if inlineID != "" {
+		return nil, errBadRequest("inline messages are not supported")
+	}
+	if chatID.ID == 0 {
+		return nil, errBadRequest("chat not found")
+	}
+	if msgID == 0 {
+		return nil, errBadRequest("message identifier is not specified")
+	}
+	msg, found, err := b.store.update(chatID.ID, msgID, func(m *Message) error {
+		if !b.isBotMessage(m) {
+			return errBadRequest("message can't be edited")
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
+		m.EditDate = b.now().Unix()
+		return nil
+	})
+	if !found {
+		return nil, errBadRequest("message to edit not found")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := b.publish(Event{Type: EventEdit, Message: &msg}); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// normalizeMarkup treats an empty inline keyboard like no keyboard
+func normalizeMarkup(m *InlineKeyboardMarkup) *InlineKeyboardMarkup {
+	if m == nil || len(m.InlineKeyboard) == 0 {
+		return nil
+	}
+	return m
+}
+
+func markupEqual(a, b *InlineKeyboardMarkup) bool {
+	return reflect.DeepEqual(normalizeMarkup(a), normalizeMarkup(b))
+}
+
+func entitiesEqual(a, b []MessageEntity) bool {
+	return len(a) == 0 && len(b) == 0 || reflect.DeepEqual(a, b)
+}
+
+// isBotMessage reports whether the bot sent m. Channel posts are sent on
+// behalf of the channel, so the bot may edit any of them.
+func (b *Bot) isBotMessage(m *Message) bool {
+	if m.Chat.Type == ChatTypeChannel {
+		return true
+	}
+	return m.From != nil && m.From.ID == b.me.ID && m.From.IsBot
+}
@@ -0,0 +1,81 @@
+package telemock
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEditMessageText(t *testing.T) {
+	t.Parallel()
+	bot := newTestBot(t)
+	defer bot.Close(context.Background())
+	conn := dialWS(t, bot)
+	defer conn.Close()
+	waitClients(t, bot, 1)
+	ctx := context.Background()
+
+	keyboard := &InlineKeyboardMarkup{InlineKeyboard: [][]InlineKeyboardButton{{{Text: "A", CallbackData: "a"}}}}
+	sent, err := bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: 4}, Text: "page 1", ReplyMarkup: keyboard})
+	require.NoError(t, err)
+
+	edited, err := bot.EditMessageText(ctx, &EditMessageTextParams{
+		ChatID: ChatID{ID: 4}, MessageID: sent.MessageID, Text: "page 2", ReplyMarkup: keyboard,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "page 2", edited.Text)
+	require.NotZero(t, edited.EditDate)
+
+	_, err = bot.EditMessageText(ctx, &EditMessageTextParams{
+		ChatID: ChatID{ID: 4}, MessageID: sent.MessageID, Text: "page 2", ReplyMarkup: keyboard,
+	})
+	require.ErrorIs(t, err, errNotModified)
+
+	// dropping the keyboard is a modification on its own
+	_, err = bot.EditMessageReplyMarkup(ctx, &EditMessageReplyMarkupParams{ChatID: ChatID{ID: 4}, MessageID: sent.MessageID})
+	require.NoError(t, err)
+	_, err = bot.EditMessageReplyMarkup(ctx, &EditMessageReplyMarkupParams{ChatID: ChatID{ID: 4}, MessageID: sent.MessageID})
+	require.ErrorIs(t, err, errNotModified)
+
+	_, err = bot.EditMessageCaption(ctx, &EditMessageCaptionParams{ChatID: ChatID{ID: 4}, MessageID: sent.MessageID, Caption: "x"})
+	require.EqualError(t, err, `telemock: api: 400 "Bad Request: there is no caption in the message to edit"`)
+
+	_, err = bot.EditMessageText(ctx, &EditMessageTextParams{ChatID: ChatID{ID: 4}, MessageID: 999, Text: "x"})
+	require.EqualError(t, err, `telemock: api: 400 "Bad Request: message to edit not found"`)
+
+	// the websocket client sees the original message followed by both edits
+	var frames []outboundPayload
+	for i := 0; i < 3; i++ {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, raw, err := conn.ReadMessage()
+		require.NoError(t, err)
+		var out outboundPayload
+		require.NoError(t, json.Unmarshal(raw, &out))
+		frames = append(frames, out)
+	}
+	require.Empty(t, frames[0].Event)
+	require.Equal(t, "edit", frames[1].Event)
+	require.Equal(t, "page 2", frames[1].Text)
+	require.NotNil(t, frames[1].ReplyMarkup)
+	require.Equal(t, "edit", frames[2].Event)
+	require.Nil(t, frames[2].ReplyMarkup)
+}
+
+func TestEditMessageText_UserMessagesCannotBeEdited(t *testing.T) {
+	t.Parallel()
+	bot, err := NewBot("token", WithoutListener(), WithoutLogFile(), WithLogger(nil))
+	require.NoError(t, err)
+	defer bot.Close(context.Background())
+
+	upd, err := bot.textUpdate(8, 0, 0, "hello")
+	require.NoError(t, err)
+	require.NoError(t, bot.Inject(upd))
+	upd = <-bot.updates
+	_, err = bot.EditMessageText(context.Background(), &EditMessageTextParams{
+		ChatID: ChatID{ID: 8}, MessageID: upd.Message.MessageID, Text: "hacked",
+	})
+	require.EqualError(t, err, `telemock: api: 400 "Bad Request: message can't be edited"`)
+}
@@ -0,0 +1,41 @@
+package telemock
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error is a Telegram Bot API error. Bot methods return it for requests the
+// real API would reject, and the HTTP API encodes it as {"ok":false,...}.
+type Error struct {
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+}
+
+// ErrBotClosed is returned by Bot methods called after Close.
+var ErrBotClosed = errors.New("telemock: bot is closed")
+
+// ErrUpdatesFull is returned by Inject when the updates buffer is full and the
+// overflow policy is OverflowReject.
+var ErrUpdatesFull = errors.New("telemock: updates buffer is full")
+
+// errUpdateDropped reports an update discarded by OverflowDropNewest. Inject
+// drops it silently; websocket clients are told.
+var errUpdateDropped = errors.New("telemock: update dropped")
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("telemock: api: %d %q", e.ErrorCode, e.Description)
+}
+
+func errBadRequest(desc string) *Error {
+	return &Error{ErrorCode: 400, Description: "Bad Request: " + desc}
+}
+
+func errForbidden(desc string) *Error {
+	return &Error{ErrorCode: 403, Description: "Forbidden: " + desc}
+}
+
+var (
+	errUnauthorized = &Error{ErrorCode: 401, Description: "Unauthorized"}
+	errNotFound     = &Error{ErrorCode: 404, Description: "Not Found"}
+)
@@ -0,0 +1,73 @@
+package telemock
+
+// EventType identifies what a bot Event describes.
+type EventType string
+
+const (
+	// EventMessage is a message sent by the bot.
+	EventMessage EventType = "message"
+	// EventEdit carries the new state of a message the bot edited.
+	EventEdit EventType = "edit"
+	// EventDelete reports messages the bot deleted, see Event.Deleted.
+	EventDelete EventType = "delete"
+	// EventChat carries a group or channel after its members changed, see
+	// Event.Members. It goes to v1 websocket clients only, not to the outbox.
+	EventChat EventType = "chat"
+)
+
+// Event is a single bot action. Every front-end (websocket clients, the
+// in-memory outbox) receives the same stream of events.
+type Event struct {
+	Type    EventType    `json:"type"`
+	Message *Message     `json:"message,omitempty"`
+	Deleted *Deleted     `json:"deleted,omitempty"`
+	Members *ChatMembers `json:"members,omitempty"`
+	// FileURL is where clients download the media of Message, if it has any.
+	FileURL string `json:"file_url,omitempty"`
+	// ReplyMarkup is the markup the message was sent with. Unlike
+	// Message.ReplyMarkup it may be a reply keyboard, its removal or a forced reply.
+	ReplyMarkup ReplyMarkup `json:"reply_markup,omitempty"`
+}
+
+// Deleted lists the messages removed from a chat by one delete call.
+type Deleted struct {
+	ChatID     int64 `json:"chat_id"`
+	MessageIDs []int `json:"message_ids"`
+}
+
+// chatID returns the chat the event belongs to
+func (ev Event) chatID() int64 {
+	switch {
+	case ev.Deleted != nil:
+		return ev.Deleted.ChatID
+	case ev.Members != nil:
+		return ev.Members.Chat.ID
+	}
+	return ev.Message.Chat.ID
+}
+
+// payload is what websocket clients receive as the v1 frame payload
+func (ev Event) payload() any {
+	switch {
+	case ev.Deleted != nil:
+		return ev.Deleted
+	case ev.Members != nil:
+		return ev.Members
+	}
+	return struct {
+		*Message
+		FileURL     string      `json:"file_url,omitempty"`
+		ReplyMarkup ReplyMarkup `json:"reply_markup,omitempty"`
+	}{ev.Message, ev.FileURL, ev.markup()}
+}
+
+// markup returns the reply markup clients should show with the message
+func (ev Event) markup() ReplyMarkup {
+	if ev.ReplyMarkup != nil {
+		return ev.ReplyMarkup
+	}
+	if ev.Message != nil && ev.Message.ReplyMarkup != nil {
+		return ev.Message.ReplyMarkup
+	}
+	return nil
+}
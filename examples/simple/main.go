@@ -1,8 +1,13 @@
 package main
 
 import (
+	"bytes"
 	"context"
+	"image"
+	"image/color"
+	"image/png"
 	"log"
+	"os"
 	"strconv"
 	"strings"
 
@@ -12,7 +17,13 @@ import (
 const API_KEY = "API Key is not being used. This const is just for reverse compatibility"
 
 func main() {
-	bot, err := telego.NewBot(API_KEY)
+	// TELEMOCK_ADDR lets the scenario test pick an ephemeral port
+	var opts []telego.BotOption
+	if addr := os.Getenv("TELEMOCK_ADDR"); addr != "" {
+		opts = append(opts, telego.WithAddr(addr))
+	}
+
+	bot, err := telego.NewBot(API_KEY, opts...)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -62,6 +73,39 @@ func main() {
 						Text:        "text",
 						ReplyMarkup: &keyboard,
 					})
+				case "/count":
+					bot.SendMessage(ctx, &telego.SendMessageParams{
+						ChatID:      telego.ChatID{ID: msg.Chat.ID},
+						Text:        "count: 0",
+						ReplyMarkup: counterKeyboard(),
+					})
+				case "/kb":
+					bot.SendMessage(ctx, &telego.SendMessageParams{
+						ChatID: telego.ChatID{ID: msg.Chat.ID},
+						Text:   "yes or no?",
+						ReplyMarkup: &telego.ReplyKeyboardMarkup{
+							Keyboard:        [][]telego.KeyboardButton{{{Text: "Yes"}, {Text: "No"}}},
+							ResizeKeyboard:  true,
+							OneTimeKeyboard: true,
+						},
+					})
+				case "/links":
+					bot.SendMessage(ctx, &telego.SendMessageParams{
+						ChatID: telego.ChatID{ID: msg.Chat.ID},
+						Text:   "links",
+						ReplyMarkup: &telego.InlineKeyboardMarkup{
+							InlineKeyboard: [][]telego.InlineKeyboardButton{
+								{{Text: "Docs", URL: "https://github.com/teterevlev/telemock-go"}},
+								{{Text: "Copy name", CopyText: &telego.CopyTextButton{Text: "telemock"}}},
+							},
+						},
+					})
+				case "/photo":
+					bot.SendPhoto(ctx, &telego.SendPhotoParams{
+						ChatID:  telego.ChatID{ID: msg.Chat.ID},
+						Photo:   telego.InputFile{File: telego.NameReader(bytes.NewReader(squarePNG()), "square.png")},
+						Caption: "a square",
+					})
 				case "/ref":
 					bot.SendMessage(ctx, &telego.SendMessageParams{
 						ChatID: telego.ChatID{ID: msg.Chat.ID},
@@ -69,9 +113,9 @@ func main() {
 					})
 				default:
 					bot.SendMessage(ctx, &telego.SendMessageParams{
-						ChatID:           telego.ChatID{ID: msg.Chat.ID},
-						Text:             "unknown command",
-						ReplyToMessageID: msg.MessageID,
+						ChatID:          telego.ChatID{ID: msg.Chat.ID},
+						Text:            "unknown command",
+						ReplyParameters: &telego.ReplyParameters{MessageID: msg.MessageID},
 					})
 				}
 				continue
@@ -79,14 +123,38 @@ func main() {
 
 			// Обычный текст
 			bot.SendMessage(ctx, &telego.SendMessageParams{
-				ChatID:           telego.ChatID{ID: msg.Chat.ID},
-				Text:             "ack",
-				ReplyToMessageID: msg.MessageID,
+				ChatID:          telego.ChatID{ID: msg.Chat.ID},
+				Text:            "ack",
+				ReplyParameters: &telego.ReplyParameters{MessageID: msg.MessageID},
 			})
 		}
 
 		if update.CallbackQuery != nil {
 			cq := update.CallbackQuery
+
+			// Счётчик редактирует своё же сообщение
+			if cq.Data == "inc" && cq.Message != nil && cq.Message.IsAccessible() {
+				n, _ := strconv.Atoi(strings.TrimPrefix(cq.Message.Message().Text, "count: "))
+				bot.EditMessageText(ctx, &telego.EditMessageTextParams{
+					ChatID:      telego.ChatID{ID: cq.Message.GetChat().ID},
+					MessageID:   cq.Message.GetMessageID(),
+					Text:        "count: " + strconv.Itoa(n+1),
+					ReplyMarkup: counterKeyboard(),
+				})
+				continue
+			}
+			if cq.Data == "done" && cq.Message != nil {
+				bot.AnswerCallbackQuery(ctx, &telego.AnswerCallbackQueryParams{
+					CallbackQueryID: cq.ID,
+					Text:            "Counter closed",
+				})
+				bot.DeleteMessage(ctx, &telego.DeleteMessageParams{
+					ChatID:    telego.ChatID{ID: cq.Message.GetChat().ID},
+					MessageID: cq.Message.GetMessageID(),
+				})
+				continue
+			}
+
 			bot.SendMessage(ctx, &telego.SendMessageParams{
 				ChatID: telego.ChatID{ID: cq.From.ID},
 				Text:   "Button pressed: " + cq.Data,
@@ -94,3 +162,26 @@ func main() {
 		}
 	}
 }
+
+// counterKeyboard is the keyboard of the /count message
+func counterKeyboard() *telego.InlineKeyboardMarkup {
+	return &telego.InlineKeyboardMarkup{
+		InlineKeyboard: [][]telego.InlineKeyboardButton{{
+			{Text: "+1", CallbackData: "inc"},
+			{Text: "Done", CallbackData: "done"},
+		}},
+	}
+}
+
+// squarePNG draws the picture sent by /photo
+func squarePNG() []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 64, 64))
+	for x := 0; x < 64; x++ {
+		for y := 0; y < 64; y++ {
+			img.Set(x, y, color.RGBA{R: 0x2a, G: 0xab, B: 0xee, A: 0xff})
+		}
+	}
+	var buf bytes.Buffer
+	png.Encode(&buf, img)
+	return buf.Bytes()
+}
@@ -5,6 +5,10 @@ import (
 	"encoding/json"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"testing"
 	"time"
@@ -19,12 +23,53 @@ type scenarioLine struct {
 	MessageID    int64           `json:"message_id"`
 	CallbackData string          `json:"callback_data"`
 	ReplyMarkup  json.RawMessage `json:"reply_markup"`
+	Event        string          `json:"event"`
+	MessageIDs   []int64         `json:"message_ids"`
+	Caption      string          `json:"caption"`
+	Media        string          `json:"media"`
+	FileURL      string          `json:"file_url"`
+	Type         string          `json:"type"` // "press" or "callback_answer"
+	ShowAlert    bool            `json:"show_alert"`
+	Action       string          `json:"action"`
+	URL          string          `json:"url"`
+	CopyText     string          `json:"copy_text"`
 }
 
+const scenarioPath = "../testdata/scenarios/simple.jsonl"
+
+// scenarioChatIDs lists the distinct chat IDs used by a scenario file
+func scenarioChatIDs(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[int64]bool)
+	var ids []string
+	for _, line := range strings.Split(string(data), "\n") {
+		var sl scenarioLine
+		if json.Unmarshal([]byte(line), &sl) != nil || seen[sl.ChatID] {
+			continue
+		}
+		seen[sl.ChatID] = true
+		ids = append(ids, strconv.FormatInt(sl.ChatID, 10))
+	}
+	return ids, nil
+}
+
+// listeningRe extracts the websocket URL from the telemock startup log line
+var listeningRe = regexp.MustCompile(`listening on (ws://\S+)`)
+
 func TestScenario_Simple(t *testing.T) {
-	// 1) Запускаем реальный бот-процесс из каталога примера
-	cmd := exec.Command("go", "run", ".")
-	cmd.Dir = "."
+	// 1) Собираем и запускаем реальный бот-процесс на эфемерном порту.
+	// Бинарник запускаем напрямую, а не через `go run`, чтобы Kill завершал сам бот.
+	bin := filepath.Join(t.TempDir(), "simple")
+	build := exec.Command("go", "build", "-o", bin, ".")
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("failed to build bot: %v\n%s", err, out)
+	}
+	cmd := exec.Command(bin)
+	cmd.Env = append(os.Environ(), "TELEMOCK_ADDR=127.0.0.1:0")
+	cmd.Dir = t.TempDir()
 
 	stdoutPipe, err := cmd.StdoutPipe()
 	if err != nil {
@@ -39,7 +84,8 @@ func TestScenario_Simple(t *testing.T) {
 		t.Fatalf("failed to start bot process: %v", err)
 	}
 
-	// Чтение stdout/stderr в отдельной горутине
+	// Чтение stdout/stderr в отдельной горутине; из лога сервера узнаем его адрес
+	wsURL := make(chan string, 1)
 	go func() {
 		scanner := bufio.NewScanner(stdoutPipe)
 		for scanner.Scan() {
@@ -49,7 +95,14 @@ func TestScenario_Simple(t *testing.T) {
 	go func() {
 		scanner := bufio.NewScanner(stderrPipe)
 		for scanner.Scan() {
-			t.Logf("[bot stderr] %s", scanner.Text())
+			line := scanner.Text()
+			t.Logf("[bot stderr] %s", line)
+			if m := listeningRe.FindStringSubmatch(line); m != nil {
+				select {
+				case wsURL <- m[1]:
+				default:
+				}
+			}
 		}
 	}()
 
@@ -57,26 +110,28 @@ func TestScenario_Simple(t *testing.T) {
 		_ = cmd.Process.Signal(os.Interrupt) // сначала сигнал прерывания
 		time.Sleep(100 * time.Millisecond)   // небольшой таймаут, чтобы процесс успел корректно завершиться
 		_ = cmd.Process.Kill()               // потом убиваем принудительно
+		_ = cmd.Wait()
 	}()
 
-	// 2) Ждем, пока WS поднимется, и подключаемся
-	d := websocket.Dialer{}
-	var ws *websocket.Conn
-	for i := 0; i < 40; i++ { // ~2s
-		wsTmp, _, err := d.Dial("ws://localhost:8765/", nil)
-		if err == nil {
-			ws = wsTmp
-			break
-		}
-		time.Sleep(50 * time.Millisecond)
+	// 2) Ждем, пока WS поднимется, и подключаемся только к чатам сценария
+	var addr string
+	select {
+	case addr = <-wsURL:
+	case <-time.After(10 * time.Second):
+		t.Fatal("telemock ws server did not report its address")
+	}
+	chatIDs, err := scenarioChatIDs(scenarioPath)
+	if err != nil {
+		t.Fatalf("failed to read scenario: %v", err)
 	}
-	if ws == nil {
+	ws, _, err := websocket.DefaultDialer.Dial(addr+"?chat_id="+strings.Join(chatIDs, ","), nil)
+	if err != nil {
 		t.Fatalf("failed to connect to telemock ws: %v", err)
 	}
 	defer ws.Close()
 
 	// 3) Открываем сценарий (относительно этого пакета)
-	f, err := os.Open("../testdata/scenarios/simple.jsonl")
+	f, err := os.Open(scenarioPath)
 	if err != nil {
 		t.Fatalf("failed to open scenario: %v", err)
 	}
@@ -96,6 +151,20 @@ func TestScenario_Simple(t *testing.T) {
 			t.Fatalf("malformed jsonl line: %v", err)
 		}
 
+		if sl.Type != "" {
+			// Служебный кадр: результат нажатия кнопки или ответ на callback query
+			_ = ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+			var got scenarioLine
+			if err := ws.ReadJSON(&got); err != nil {
+				t.Fatalf("expected %s frame, read error: %v", sl.Type, err)
+			}
+			if got.Type != sl.Type || got.ChatID != sl.ChatID || got.MessageID != sl.MessageID || got.Action != sl.Action ||
+				got.URL != sl.URL || got.CopyText != sl.CopyText || got.Text != sl.Text || got.ShowAlert != sl.ShowAlert {
+				t.Fatalf("unexpected %s frame: got %+v, want %+v", sl.Type, got, sl)
+			}
+			continue
+		}
+
 		if sl.From == "bot" {
 			// Ждем ответ бота и сравниваем
 			_ = ws.SetReadDeadline(time.Now().Add(2 * time.Second))
@@ -114,6 +183,22 @@ func TestScenario_Simple(t *testing.T) {
 					got.ChatID, got.Text, got.From, sl.ChatID, sl.Text)
 			}
 
+			if got.Event != sl.Event {
+				t.Fatalf("unexpected bot event: got %q, want %q", got.Event, sl.Event)
+			}
+			if sl.Event == "edit" && got.MessageID != sl.MessageID {
+				t.Fatalf("edit of wrong message: got message_id=%d, want %d", got.MessageID, sl.MessageID)
+			}
+			if got.Caption != sl.Caption || got.Media != sl.Media {
+				t.Fatalf("unexpected media: got %s %q, want %s %q", got.Media, got.Caption, sl.Media, sl.Caption)
+			}
+			if sl.Media != "" && got.FileURL == "" {
+				t.Fatalf("media message without file_url")
+			}
+			if sl.Event == "delete" && !slices.Equal(got.MessageIDs, sl.MessageIDs) {
+				t.Fatalf("deleted messages: got %v, want %v", got.MessageIDs, sl.MessageIDs)
+			}
+
 			if len(sl.ReplyMarkup) > 0 {
 				if len(got.ReplyMarkup) == 0 {
 					t.Fatalf("expected reply_markup, but none present in bot message")
@@ -0,0 +1,381 @@
+package telemock
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// NamedReader is uploaded file content together with its file name; *os.File
+// implements it.
+type NamedReader interface {
+	io.Reader
+	Name() string
+}
+
+type nameReader struct {
+	io.Reader
+	name string
+}
+
+func (r nameReader) Name() string { return r.name }
+
+// NameReader gives r a file name so it can be uploaded as InputFile.File.
+func NameReader(r io.Reader, name string) NamedReader {
+	return nameReader{Reader: r, name: name}
+}
+
+// InputFile is a file to send. Exactly one source is used, in this order:
+// FileID of a file telemock already stores, URL, or File content. URL is an
+// http(s) link, which is kept as is and never fetched, or a file:// path in
+// the directory given to WithLocalFiles, which is read like the local Bot API
+// server does.
+type InputFile struct {
+	File   NamedReader
+	FileID string
+	URL    string
+
+	attach string // multipart part named by "attach://<name>"
+}
+
+// attachPrefix references a multipart part from a parameter value
+const attachPrefix = "attach://"
+
+func (i InputFile) MarshalJSON() ([]byte, error) {
+	switch {
+	case i.FileID != "":
+		return json.Marshal(i.FileID)
+	case i.URL != "":
+		return json.Marshal(i.URL)
+	case i.File != nil:
+		return []byte(`""`), nil
+	}
+	return nil, fmt.Errorf("telemock: file ID, URL and file are empty")
+}
+
+// UnmarshalJSON reads the string form the Bot API uses: a file_id, an URL or
+// attach://<name>. Uploaded content is filled in by decodeParams.
+func (i *InputFile) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	*i = InputFile{}
+	switch {
+	case s == "":
+	case strings.HasPrefix(s, attachPrefix):
+		i.attach = strings.TrimPrefix(s, attachPrefix)
+	case strings.HasPrefix(s, "http://"), strings.HasPrefix(s, "https://"), strings.HasPrefix(s, "file://"):
+		i.URL = s
+	default:
+		i.FileID = s
+	}
+	return nil
+}
+
+// maxUploadSize is the Bot API limit on files uploaded by bots
+const maxUploadSize = 50 << 20
+
+var (
+	errWrongFile     = errBadRequest("wrong file identifier/HTTP URL specified")
+	errWrongFileType = errBadRequest("wrong file type")
+)
+
+// storedFile is a file telemock received from the bot or a client
+type storedFile struct {
+	kind     string // photo, document, ...: what the file may be sent as
+	id       string
+	uniqueID string
+	path     string // file_path, relative to /file/bot<token>/
+	name     string
+	mimeType string
+	data     []byte
+	url      string // remote files are referenced, not downloaded
+}
+
+func (f *storedFile) size() int64 {
+	return int64(len(f.data))
+}
+
+// fileStore keeps every file by file_id and by file_path
+type fileStore struct {
+	mu     sync.RWMutex
+	next   int
+	byID   map[string]*storedFile
+	byPath map[string]*storedFile
+}
+
+func newFileStore() *fileStore {
+	return &fileStore{byID: make(map[string]*storedFile), byPath: make(map[string]*storedFile)}
+}
+
+// add stores a new file of the given kind ("photo", "document", ...). The
+// file_unique_id depends only on the content, like in Telegram.
+func (s *fileStore) add(kind, name, mimeType string, data []byte, remote string) *storedFile {
+	h := sha256.New()
+	h.Write(data)
+	h.Write([]byte(remote))
+	sum := h.Sum(nil)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.next++
+	ext := path.Ext(name)
+	if ext == "" {
+		if exts, _ := mime.ExtensionsByType(mimeType); len(exts) > 0 {
+			ext = exts[0]
+		}
+	}
+	f := &storedFile{
+		kind:     kind,
+		id:       fmt.Sprintf("%s-%d", kind, s.next),
+		uniqueID: base64.RawURLEncoding.EncodeToString(sum[:9]),
+		path:     fmt.Sprintf("%ss/file_%d%s", kind, s.next, ext),
+		name:     name,
+		mimeType: mimeType,
+		data:     data,
+		url:      remote,
+	}
+	s.byID[f.id] = f
+	s.byPath[f.path] = f
+	return f
+}
+
+func (s *fileStore) get(id string) (*storedFile, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	f, ok := s.byID[id]
+	return f, ok
+}
+
+// remove forgets an uploaded file; a nil f is ignored
+func (s *fileStore) remove(f *storedFile) {
+	if f == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.byID, f.id)
+	delete(s.byPath, f.path)
+}
+
+// getAs returns a stored file to be sent as kind. Like in Telegram, a file
+// keeps its kind; an animation is a document too.
+func (s *fileStore) getAs(id, kind string) (*storedFile, error) {
+	f, ok := s.get(id)
+	switch {
+	case !ok:
+		return nil, errWrongFile
+	case f.kind != kind && !(f.kind == "animation" && kind == "document"):
+		return nil, errWrongFileType
+	}
+	return f, nil
+}
+
+func (s *fileStore) getPath(p string) (*storedFile, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	f, ok := s.byPath[p]
+	return f, ok
+}
+
+// resolveFile turns an InputFile into a stored file, uploading its content
+// when needed
+func (b *Bot) resolveFile(kind string, in InputFile) (*storedFile, error) {
+	switch {
+	case in.FileID != "":
+		return b.files.getAs(in.FileID, kind)
+	case strings.HasPrefix(in.URL, "file://"):
+		name, ok := b.localFile(in.URL)
+		if !ok {
+			return nil, errWrongFile
+		}
+		file, err := os.Open(name)
+		if err != nil {
+			return nil, errWrongFile
+		}
+		defer file.Close()
+		return b.uploadFile(kind, file)
+	case in.URL != "":
+		u, err := url.Parse(in.URL)
+		if err != nil || u.Host == "" {
+			return nil, errWrongFile
+		}
+		name := path.Base(u.Path)
+		return b.files.add(kind, name, mime.TypeByExtension(path.Ext(name)), nil, in.URL), nil
+	case in.File != nil:
+		return b.uploadFile(kind, in.File)
+	}
+	return nil, errBadRequest("there is no " + kind + " in the request")
+}
+
+// localFile maps a file:// URL to a path inside the WithLocalFiles directory
+func (b *Bot) localFile(rawURL string) (string, bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || b.localDir == "" {
+		return "", false
+	}
+	// ссылки раскрываем, чтобы symlink не выводил за пределы каталога
+	dir, err := filepath.EvalSymlinks(b.localDir)
+	if err != nil {
+		return "", false
+	}
+	name, err := filepath.EvalSymlinks(filepath.Clean(u.Path))
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(dir, name)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return name, true
+}
+
+func (b *Bot) uploadFile(kind string, r NamedReader) (*storedFile, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxUploadSize+1))
+	if err != nil {
+		return nil, errBadRequest("can't read file: " + err.Error())
+	}
+	if len(data) > maxUploadSize {
+		return nil, &Error{ErrorCode: http.StatusRequestEntityTooLarge, Description: "Request Entity Too Large"}
+	}
+	if len(data) == 0 {
+		return nil, errBadRequest("file must be non-empty")
+	}
+	return b.files.upload(kind, r.Name(), "", data), nil
+}
+
+// upload stores uploaded content; an empty mimeType is guessed from the name
+// and the content
+func (s *fileStore) upload(kind, name, mimeType string, data []byte) *storedFile {
+	if name != "" {
+		name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	}
+	if mimeType == "" {
+		mimeType = mime.TypeByExtension(path.Ext(name))
+	}
+	if mimeType == "" {
+		mimeType = http.DetectContentType(data)
+	}
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+	return s.add(kind, name, mimeType, data, "")
+}
+
+// imageSize reports the dimensions of a GIF, JPEG or PNG image, or zeros
+func imageSize(data []byte) (width, height int) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return 0, 0
+	}
+	return cfg.Width, cfg.Height
+}
+
+// mediaURL is where clients download the media of msg; empty without media
+func (b *Bot) mediaURL(msg *Message) string {
+	if _, id := mediaOf(msg); id != "" {
+		if f, ok := b.files.get(id); ok {
+			return b.fileURL(f)
+		}
+	}
+	return ""
+}
+
+// fileURL returns where clients download a stored file: the file endpoint for
+// uploads and the original link for remote files
+func (b *Bot) fileURL(f *storedFile) string {
+	if f.url != "" {
+		return f.url
+	}
+	return b.FileDownloadURL(f.path)
+}
+
+// serveFile answers /file/bot<token>/<file_path> downloads the way Telegram's
+// file server does, including its JSON 404
+func (b *Bot) serveFile(w http.ResponseWriter, r *http.Request, token, filePath string) {
+	f, ok := b.files.getPath(filePath)
+	switch {
+	case token != b.token || !ok:
+		writeFileError(w, errNotFound)
+		return
+	case f.size() > maxDownloadSize:
+		writeFileError(w, errFileTooBig)
+		return
+	}
+	if f.url != "" {
+		http.Redirect(w, r, f.url, http.StatusFound)
+		return
+	}
+	if f.mimeType != "" {
+		w.Header().Set("Content-Type", f.mimeType)
+	}
+	http.ServeContent(w, r, f.name, time.Time{}, bytes.NewReader(f.data))
+}
+
+// writeFileError answers a download with a Bot API error
+func writeFileError(w http.ResponseWriter, err *Error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.ErrorCode)
+	_ = json.NewEncoder(w).Encode(apiResponse{ErrorCode: err.ErrorCode, Description: err.Description})
+}
+
+// File is a file ready to be downloaded from FileDownloadURL(FilePath).
+type File struct {
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	FileSize     int64  `json:"file_size,omitempty"`
+	FilePath     string `json:"file_path,omitempty"`
+}
+
+type GetFileParams struct {
+	FileID string `json:"file_id"`
+}
+
+// maxDownloadSize is the Bot API limit on files bots can download
+const maxDownloadSize = 20 << 20
+
+var errFileTooBig = errBadRequest("file is too big")
+
+// GetFile returns the download path of a stored file. Like Telegram, it
+// refuses files bigger than 20 MB.
+func (b *Bot) GetFile(ctx context.Context, params *GetFileParams) (*File, error) {
+	if params == nil {
+		return nil, errors.New("nil params")
+	}
+	if b.isClosed() {
+		return nil, ErrBotClosed
+	}
+	fileID := params.FileID
+	if fileID == "" {
+		return nil, errBadRequest("file_id not specified")
+	}
+	f, ok := b.files.get(fileID)
+	if !ok {
+		return nil, errBadRequest("invalid file_id")
+	}
+	if f.size() > maxDownloadSize {
+		return nil, errFileTooBig
+	}
+	return &File{FileID: f.id, FileUniqueID: f.uniqueID, FileSize: f.size(), FilePath: f.path}, nil
+}
+
+// FileDownloadURL returns the URL a File is downloaded from.
+func (b *Bot) FileDownloadURL(filePath string) string {
+	return b.APIURL() + "/file/bot" + b.token + "/" + filePath
+}
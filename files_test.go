@@ -0,0 +1,58 @@
+package telemock
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetFile(t *testing.T) {
+	t.Parallel()
+	bot := newTestBot(t)
+	defer bot.Close(context.Background())
+	ctx := context.Background()
+
+	// a document uploaded by a user
+	doc := bot.files.upload("document", "scan.txt", "", []byte("invoice #1"))
+
+	file, err := bot.GetFile(ctx, &GetFileParams{FileID: doc.id})
+	require.NoError(t, err)
+	require.Equal(t, &File{FileID: doc.id, FileUniqueID: doc.uniqueID, FileSize: 10, FilePath: "documents/file_1.txt"}, file)
+
+	resp, err := http.Get(bot.FileDownloadURL(file.FilePath))
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "invoice #1", string(body))
+
+	resp, err = http.Get(bot.FileDownloadURL("documents/missing.txt"))
+	require.NoError(t, err)
+	var env apiResponse
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&env))
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+	require.Equal(t, apiResponse{ErrorCode: 404, Description: "Not Found"}, env)
+
+	big := bot.files.upload("video", "big.mp4", "", make([]byte, maxDownloadSize+1))
+	_, err = bot.GetFile(ctx, &GetFileParams{FileID: big.id})
+	require.EqualError(t, err, `telemock: api: 400 "Bad Request: file is too big"`)
+	resp, err = http.Get(bot.FileDownloadURL(big.path))
+	require.NoError(t, err)
+	env = apiResponse{}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&env))
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Equal(t, apiResponse{ErrorCode: 400, Description: "Bad Request: file is too big"}, env)
+	_, err = bot.GetFile(ctx, &GetFileParams{FileID: "nope"})
+	require.EqualError(t, err, `telemock: api: 400 "Bad Request: invalid file_id"`)
+
+	status, apiEnv, result := callAPI(t, bot, "token", "getFile", `{"file_id":"`+doc.id+`"}`)
+	require.Equal(t, http.StatusOK, status)
+	require.True(t, apiEnv.Ok)
+	require.JSONEq(t, `{"file_id":"`+doc.id+`","file_unique_id":"`+doc.uniqueID+`","file_size":10,"file_path":"documents/file_1.txt"}`, string(result))
+}
@@ -0,0 +1,92 @@
+package telemock
+
+// History returns the messages of a chat, oldest first, both the bot's and
+// the users'. Edited messages are in their latest state and deleted ones are
+// gone. The messages are copies.
+func (b *Bot) History(chatID int64) []Message {
+	stored := b.store.history(chatID)
+	msgs := make([]Message, 0, len(stored))
+	for _, sm := range stored {
+		msgs = append(msgs, sm.msg)
+	}
+	return msgs
+}
+
+// Change is one step of a chat's history: a message sent, edited or deleted.
+type Change struct {
+	// Type is EventMessage, EventEdit or EventDelete.
+	Type EventType
+	// FromBot tells the bot's changes from those of users.
+	FromBot bool
+	// Message is the message after the change; for a deletion, the message
+	// as it was deleted.
+	Message Message
+	// Previous is the version an edit replaced.
+	Previous *Message
+}
+
+// Changes returns everything that happened to the messages of a chat, oldest
+// first. Unlike History it keeps the versions edits replaced and the messages
+// that were deleted, so tests can check that the bot edited or removed one.
+func (b *Bot) Changes(chatID int64) []Change {
+	return b.store.changes(chatID)
+}
+
+// LastMessage returns the newest message of a chat.
+func (b *Bot) LastMessage(chatID int64) (Message, bool) {
+	return b.store.last(chatID)
+}
+
+// Chats returns every chat with a history, and every group or channel, in the
+// order telemock first saw them.
+func (b *Bot) Chats() []Chat {
+	ids := b.store.chatIDs()
+	chats := make([]Chat, 0, len(ids))
+	for _, id := range ids {
+		if chat, err := b.chat(id); err == nil {
+			chats = append(chats, chat)
+		}
+	}
+	return chats
+}
+
+// replayHistory sends a newly connected v1 client the history of every chat
+// it follows, so a reloaded page can rebuild its chats. Groups and channels
+// are announced with their members first.
+func (b *Bot) replayHistory(c *client) {
+	if c.protocol() < 1 {
+		return
+	}
+	for _, chat := range b.Chats() {
+		if !c.wants(chat.ID) {
+			continue
+		}
+		if chat.Type != ChatTypePrivate {
+			members := &ChatMembers{Chat: chat, Members: b.store.members(chat.ID)}
+			b.sendFrame(c, newEnvelope(string(EventChat), nil, members))
+		}
+		frame := historyFrame{Chat: chat, Messages: []historyMessage{}}
+		for _, sm := range b.store.history(chat.ID) {
+			msg := sm.msg
+			frame.Messages = append(frame.Messages, historyMessage{Message: &msg, FileURL: b.mediaURL(&msg), FromBot: sm.fromBot})
+		}
+		if kb := b.store.keyboard(chat.ID); kb != nil {
+			frame.ReplyMarkup = kb
+		}
+		b.sendFrame(c, newEnvelope("history", nil, frame))
+	}
+}
+
+// historyFrame is the payload of a "history" frame: the stored messages of a
+// chat and its active reply keyboard
+type historyFrame struct {
+	Chat        Chat                 `json:"chat"`
+	Messages    []historyMessage     `json:"messages"`
+	ReplyMarkup *ReplyKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+type historyMessage struct {
+	*Message
+	FileURL string `json:"file_url,omitempty"`
+	FromBot bool   `json:"from_bot,omitempty"`
+}
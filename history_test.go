@@ -0,0 +1,200 @@
+package telemock
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	t.Parallel()
+	bot, err := NewBot("token", WithoutListener(), WithoutLogFile(), WithLogger(nil))
+	require.NoError(t, err)
+	defer bot.Close(context.Background())
+	ctx := context.Background()
+
+	require.Empty(t, bot.History(1))
+	_, ok := bot.LastMessage(1)
+	require.False(t, ok)
+
+	hello, err := bot.textUpdate(1, 0, 0, "hello")
+	require.NoError(t, err)
+	require.NoError(t, bot.Inject(hello))
+	first, err := bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: 1}, Text: "one"})
+	require.NoError(t, err)
+	second, err := bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: 1}, Text: "two"})
+	require.NoError(t, err)
+	require.NoError(t, bot.CreateChat(Chat{ID: -100, Type: ChatTypeGroup, Title: "Team"}))
+	_, err = bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: 2}, Text: "elsewhere"})
+	require.NoError(t, err)
+
+	// edits and deletions are reflected
+	edited, err := bot.EditMessageText(ctx, &EditMessageTextParams{ChatID: ChatID{ID: 1}, MessageID: first.MessageID, Text: "uno"})
+	require.NoError(t, err)
+	require.NoError(t, bot.DeleteMessage(ctx, &DeleteMessageParams{ChatID: ChatID{ID: 1}, MessageID: second.MessageID}))
+
+	history := bot.History(1)
+	require.Len(t, history, 2)
+	require.Equal(t, "hello", history[0].Text)
+	require.Equal(t, *edited, history[1])
+	last, ok := bot.LastMessage(1)
+	require.True(t, ok)
+	require.Equal(t, "uno", last.Text)
+
+	// the changes keep what was edited and deleted
+	userEdit, err := bot.editUpdate(1, 0, hello.Message.MessageID, "hello!")
+	require.NoError(t, err)
+	require.NoError(t, bot.Inject(userEdit))
+	type step struct {
+		typ      EventType
+		fromBot  bool
+		text     string
+		previous string
+	}
+	var steps []step
+	for _, c := range bot.Changes(1) {
+		s := step{typ: c.Type, fromBot: c.FromBot, text: c.Message.Text}
+		if c.Previous != nil {
+			s.previous = c.Previous.Text
+		}
+		steps = append(steps, s)
+	}
+	require.Equal(t, []step{
+		{EventMessage, false, "hello", ""},
+		{EventMessage, true, "one", ""},
+		{EventMessage, true, "two", ""},
+		{EventEdit, true, "uno", "one"},
+		{EventDelete, true, "two", ""},
+		{EventEdit, false, "hello!", "hello"},
+	}, steps)
+	require.Empty(t, bot.Changes(3))
+
+	var ids []int64
+	for _, chat := range bot.Chats() {
+		ids = append(ids, chat.ID)
+	}
+	require.Equal(t, []int64{1, -100, 2}, ids)
+}
+
+func TestHistory_InjectedMessagesWithoutIDs(t *testing.T) {
+	t.Parallel()
+	clock := NewMockClock(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC))
+	bot, err := NewBot("token", WithoutListener(), WithoutLogFile(), WithLogger(nil), WithClock(clock))
+	require.NoError(t, err)
+	defer bot.Close(context.Background())
+
+	for _, text := range []string{"/start", "/help"} {
+		require.NoError(t, bot.Inject(Update{Message: &Message{Chat: Chat{ID: 1, Type: ChatTypePrivate}, Text: text}}))
+	}
+	history := bot.History(1)
+	require.Len(t, history, 2)
+	require.NotZero(t, history[0].MessageID)
+	require.NotEqual(t, history[0].MessageID, history[1].MessageID)
+	require.Equal(t, clock.Now().Unix(), history[1].Date)
+	for _, c := range bot.Changes(1) {
+		require.Equal(t, EventMessage, c.Type)
+	}
+	// the bot gets the same IDs
+	require.Equal(t, history[0].MessageID, (<-bot.updates).Message.MessageID)
+}
+
+func TestHistory_Replay(t *testing.T) {
+	t.Parallel()
+	bot := newTestBot(t)
+	defer bot.Close(context.Background())
+	ctx := context.Background()
+
+	require.NoError(t, bot.SetUserProfile(User{ID: 5, FirstName: "Eve"}))
+	hi, err := bot.textUpdate(5, 0, 0, "hi")
+	require.NoError(t, err)
+	require.NoError(t, bot.Inject(hi))
+	_, err = bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: 5}, Text: "pick",
+		ReplyMarkup: &ReplyKeyboardMarkup{Keyboard: [][]KeyboardButton{{{Text: "A"}}}}})
+	require.NoError(t, err)
+	group := Chat{ID: -300, Type: ChatTypeSupergroup, Title: "Team"}
+	require.NoError(t, bot.CreateChat(group))
+	_, err = bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: 6}, Text: "not for you"})
+	require.NoError(t, err)
+
+	conn, _, err := websocket.DefaultDialer.Dial(bot.URL()+"?v=1&chat_id=5,-300", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	env := readEnvelope(t, conn)
+	require.Equal(t, "history", env.Type)
+	var h struct {
+		Chat     Chat `json:"chat"`
+		Messages []struct {
+			Message
+			FromBot bool `json:"from_bot"`
+		} `json:"messages"`
+		ReplyMarkup *ReplyKeyboardMarkup `json:"reply_markup"`
+	}
+	require.NoError(t, json.Unmarshal(env.Payload, &h))
+	require.Equal(t, "Eve", h.Chat.FirstName)
+	require.Len(t, h.Messages, 2)
+	require.Equal(t, "hi", h.Messages[0].Text)
+	require.False(t, h.Messages[0].FromBot)
+	require.Equal(t, "pick", h.Messages[1].Text)
+	require.True(t, h.Messages[1].FromBot)
+	require.Equal(t, "A", h.ReplyMarkup.Keyboard[0][0].Text)
+
+	// a group comes with its members, then its (empty) history
+	require.Equal(t, "chat", readEnvelope(t, conn).Type)
+	env = readEnvelope(t, conn)
+	require.Equal(t, "history", env.Type)
+	require.JSONEq(t, `{"chat":{"id":-300,"type":"supergroup","title":"Team"},"messages":[]}`, string(env.Payload))
+
+	// v0 clients get no replay
+	legacy := dialWS(t, bot)
+	defer legacy.Close()
+	_, err = bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: 5}, Text: "live"})
+	require.NoError(t, err)
+	var out outboundPayload
+	_, raw, err := legacy.ReadMessage()
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(raw, &out))
+	require.Equal(t, "live", out.Text)
+}
+
+func TestHistory_LiveMessagesWaitForReplay(t *testing.T) {
+	t.Parallel()
+	bot := newTestBot(t)
+	defer bot.Close(context.Background())
+
+	// клиент уже зарегистрирован, но история ещё не отправлена
+	c := newClient(nil, 8)
+	c.setProtocol(1)
+	c.holding = true
+	bot.mu.Lock()
+	bot.clients[c] = struct{}{}
+	bot.mu.Unlock()
+	defer func() {
+		bot.mu.Lock()
+		delete(bot.clients, c)
+		bot.mu.Unlock()
+	}()
+
+	_, err := bot.SendMessage(context.Background(), &SendMessageParams{ChatID: ChatID{ID: 5}, Text: "live"})
+	require.NoError(t, err)
+	require.Empty(t, c.send)
+
+	bot.replayHistory(c)
+	bot.releaseHeld(c)
+	var types []string
+	for len(c.send) > 0 {
+		var env envelope
+		require.NoError(t, json.Unmarshal(<-c.send, &env))
+		types = append(types, env.Type)
+	}
+	require.Equal(t, []string{"history", "message"}, types)
+
+	// после повтора события идут напрямую
+	_, err = bot.SendMessage(context.Background(), &SendMessageParams{ChatID: ChatID{ID: 5}, Text: "next"})
+	require.NoError(t, err)
+	require.Len(t, c.send, 1)
+}
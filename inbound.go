@@ -0,0 +1,98 @@
+package telemock
+
+import "strings"
+
+// fileFrame is a file attached to a client "message" frame. Data is base64 in
+// JSON. FileID sends a file telemock already stores instead of new content.
+type fileFrame struct {
+	FileID   string `json:"file_id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	MimeType string `json:"mime_type,omitempty"`
+	Data     []byte `json:"data,omitempty"`
+	Duration int    `json:"duration,omitempty"` // voice
+	Emoji    string `json:"emoji,omitempty"`    // sticker
+}
+
+// hasMedia reports whether a message frame carries anything but text
+func (p *messageFrame) hasMedia() bool {
+	return p.Photo != nil || p.Document != nil || p.Voice != nil || p.Sticker != nil ||
+		p.Location != nil || p.Contact != nil
+}
+
+// attachMedia adds the media of a client message frame to msg, storing
+// uploaded files. Like in Telegram, text sent with a file becomes its caption.
+// It returns the file it uploaded, if any, to be removed should the message
+// fail later.
+func (b *Bot) attachMedia(msg *Message, p *messageFrame) (uploaded *storedFile, err error) {
+	file := func(kind string, ff *fileFrame) (*storedFile, error) {
+		f, err := b.clientFile(kind, ff)
+		if err == nil && ff.FileID == "" {
+			uploaded = f
+		}
+		return f, err
+	}
+	if p.Photo != nil || p.Document != nil || p.Voice != nil || p.Sticker != nil {
+		msg.Caption, msg.Text, msg.Entities = p.Caption, "", nil
+		if msg.Caption == "" {
+			msg.Caption = p.Text
+		}
+	}
+	switch {
+	case p.Photo != nil:
+		f, err := file("photo", p.Photo)
+		if err != nil {
+			return nil, err
+		}
+		w, h := imageSize(f.data)
+		msg.Photo = []PhotoSize{{FileID: f.id, FileUniqueID: f.uniqueID, Width: w, Height: h, FileSize: int(f.size())}}
+	case p.Document != nil:
+		f, err := file("document", p.Document)
+		if err != nil {
+			return nil, err
+		}
+		msg.Document = documentOf(f)
+	case p.Voice != nil:
+		if p.Voice.MimeType == "" {
+			p.Voice.MimeType = "audio/ogg"
+		}
+		f, err := file("voice", p.Voice)
+		if err != nil {
+			return nil, err
+		}
+		msg.Voice = &Voice{FileID: f.id, FileUniqueID: f.uniqueID, Duration: p.Voice.Duration, MimeType: f.mimeType, FileSize: f.size()}
+	case p.Sticker != nil:
+		f, err := file("sticker", p.Sticker)
+		if err != nil {
+			return nil, err
+		}
+		w, h := imageSize(f.data)
+		if w == 0 {
+			w, h = 512, 512
+		}
+		msg.Sticker = &Sticker{FileID: f.id, FileUniqueID: f.uniqueID, Type: "regular", Width: w, Height: h,
+			IsVideo: strings.HasPrefix(f.mimeType, "video/"), Emoji: p.Sticker.Emoji, FileSize: int(f.size())}
+	case p.Location != nil:
+		if p.Location.Latitude < -90 || p.Location.Latitude > 90 || p.Location.Longitude < -180 || p.Location.Longitude > 180 {
+			return nil, errBadRequest("invalid location")
+		}
+		msg.Location, msg.Text, msg.Entities = p.Location, "", nil
+	case p.Contact != nil:
+		if p.Contact.PhoneNumber == "" || p.Contact.FirstName == "" {
+			return nil, errBadRequest("contact needs phone_number and first_name")
+		}
+		msg.Contact, msg.Text, msg.Entities = p.Contact, "", nil
+	}
+	return uploaded, nil
+}
+
+// clientFile stores a file uploaded by a simulated user. Users may upload
+// files larger than bots can, so no size limit applies.
+func (b *Bot) clientFile(kind string, ff *fileFrame) (*storedFile, error) {
+	if ff.FileID != "" {
+		return b.files.getAs(ff.FileID, kind)
+	}
+	if len(ff.Data) == 0 {
+		return nil, errBadRequest(kind + " data is empty")
+	}
+	return b.files.upload(kind, ff.Name, ff.MimeType, ff.Data), nil
+}
@@ -0,0 +1,123 @@
+package telemock
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// ReplyMarkup is the reply_markup of a sent message: an inline keyboard, a
+// custom reply keyboard, a request to remove it, or a forced reply. As in
+// telego, only the types of this package implement it.
+type ReplyMarkup interface {
+	// ReplyType returns the Bot API name of the markup type
+	ReplyType() string
+	iReplyMarkup()
+}
+
+// ReplyMarkup types
+const (
+	MarkupTypeReplyKeyboard       = "ReplyKeyboardMarkup"
+	MarkupTypeReplyKeyboardRemove = "ReplyKeyboardRemove"
+	MarkupTypeInlineKeyboard      = "InlineKeyboardMarkup"
+	MarkupTypeForceReply          = "ForceReply"
+)
+
+// ReplyKeyboardMarkup replaces the user's keyboard with custom buttons; a
+// pressed button sends its text.
+type ReplyKeyboardMarkup struct {
+	Keyboard              [][]KeyboardButton `json:"keyboard"`
+	IsPersistent          bool               `json:"is_persistent,omitempty"`
+	ResizeKeyboard        bool               `json:"resize_keyboard,omitempty"`
+	OneTimeKeyboard       bool               `json:"one_time_keyboard,omitempty"`
+	InputFieldPlaceholder string             `json:"input_field_placeholder,omitempty"`
+	Selective             bool               `json:"selective,omitempty"`
+}
+
+type KeyboardButton struct {
+	Text            string `json:"text"`
+	RequestContact  bool   `json:"request_contact,omitempty"`
+	RequestLocation bool   `json:"request_location,omitempty"`
+}
+
+// ReplyKeyboardRemove hides the chat's reply keyboard.
+type ReplyKeyboardRemove struct {
+	RemoveKeyboard bool `json:"remove_keyboard"`
+	Selective      bool `json:"selective,omitempty"`
+}
+
+// ForceReply makes the client start a reply to the message.
+type ForceReply struct {
+	ForceReply            bool   `json:"force_reply"`
+	InputFieldPlaceholder string `json:"input_field_placeholder,omitempty"`
+	Selective             bool   `json:"selective,omitempty"`
+}
+
+func (r *ReplyKeyboardMarkup) ReplyType() string  { return MarkupTypeReplyKeyboard }
+func (r *ReplyKeyboardRemove) ReplyType() string  { return MarkupTypeReplyKeyboardRemove }
+func (i *InlineKeyboardMarkup) ReplyType() string { return MarkupTypeInlineKeyboard }
+func (f *ForceReply) ReplyType() string           { return MarkupTypeForceReply }
+
+func (r *ReplyKeyboardMarkup) iReplyMarkup()  {}
+func (r *ReplyKeyboardRemove) iReplyMarkup()  {}
+func (i *InlineKeyboardMarkup) iReplyMarkup() {}
+func (f *ForceReply) iReplyMarkup()           {}
+
+// replyMarkupType is reflect.Type of the ReplyMarkup interface, for decodeParams
+var replyMarkupType = reflect.TypeOf((*ReplyMarkup)(nil)).Elem()
+
+// parseReplyMarkup decodes a reply_markup object, telling the types apart by
+// their required field
+func parseReplyMarkup(data []byte) (ReplyMarkup, error) {
+	var probe map[string]json.RawMessage
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return nil, errBadRequest("can't parse reply keyboard markup JSON object")
+	}
+	var markup ReplyMarkup
+	switch {
+	case probe["inline_keyboard"] != nil:
+		markup = &InlineKeyboardMarkup{}
+	case probe["keyboard"] != nil:
+		markup = &ReplyKeyboardMarkup{}
+	case probe["remove_keyboard"] != nil:
+		markup = &ReplyKeyboardRemove{}
+	case probe["force_reply"] != nil:
+		markup = &ForceReply{}
+	default:
+		return nil, errBadRequest("can't parse reply keyboard markup JSON object")
+	}
+	if err := json.Unmarshal(data, markup); err != nil {
+		return nil, errBadRequest("can't parse reply keyboard markup JSON object")
+	}
+	return markup, nil
+}
+
+// normalizeReplyMarkup turns a typed nil pointer into a nil interface
+func normalizeReplyMarkup(markup ReplyMarkup) ReplyMarkup {
+	if markup == nil || reflect.ValueOf(markup).IsNil() {
+		return nil
+	}
+	return markup
+}
+
+// inlineKeyboard returns markup if it is an inline keyboard. Other markup
+// types don't stay attached to the message.
+func inlineKeyboard(markup ReplyMarkup) *InlineKeyboardMarkup {
+	ik, _ := markup.(*InlineKeyboardMarkup)
+	return ik
+}
+
+// pressed reports whether msg is what pressing one of the keyboard's buttons
+// sends: its text, or a shared contact or location for request buttons
+func (k *ReplyKeyboardMarkup) pressed(msg *Message) bool {
+	for _, row := range k.Keyboard {
+		for _, btn := range row {
+			switch {
+			case btn.RequestContact && msg.Contact != nil,
+				btn.RequestLocation && msg.Location != nil,
+				!btn.RequestContact && !btn.RequestLocation && msg.Text != "" && btn.Text == msg.Text:
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,103 @@
+package telemock
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReplyKeyboard_OneTime(t *testing.T) {
+	t.Parallel()
+	bot, err := NewBot("token", WithoutListener(), WithoutLogFile(), WithLogger(nil))
+	require.NoError(t, err)
+	defer bot.Close(context.Background())
+	ctx := context.Background()
+
+	keyboard := &ReplyKeyboardMarkup{
+		Keyboard: [][]KeyboardButton{
+			{{Text: "Yes"}, {Text: "No"}},
+			{{Text: "Share phone", RequestContact: true}},
+		},
+		OneTimeKeyboard: true,
+	}
+	msg, err := bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: 1}, Text: "sure?", ReplyMarkup: keyboard})
+	require.NoError(t, err)
+	require.Nil(t, msg.ReplyMarkup, "reply keyboards don't stay on the message")
+	ev := <-bot.Outbox()
+	require.Equal(t, keyboard, ev.ReplyMarkup)
+	require.Equal(t, keyboard, bot.ReplyKeyboard(1))
+
+	text := func(s string) Update {
+		upd, err := bot.textUpdate(1, 0, 0, s)
+		require.NoError(t, err)
+		return upd
+	}
+
+	// typing something else keeps the keyboard
+	require.NoError(t, bot.Inject(text("maybe")))
+	require.Equal(t, keyboard, bot.ReplyKeyboard(1))
+
+	// sharing the contact presses the request_contact button
+	upd := text("")
+	upd.Message.Contact = &Contact{PhoneNumber: "+100", FirstName: "Ann"}
+	require.NoError(t, bot.Inject(upd))
+	require.Nil(t, bot.ReplyKeyboard(1))
+
+	// a persistent keyboard stays until the bot removes it
+	keyboard.OneTimeKeyboard = false
+	_, err = bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: 1}, Text: "again", ReplyMarkup: keyboard})
+	require.NoError(t, err)
+	require.NoError(t, bot.Inject(text("Yes")))
+	require.Equal(t, keyboard, bot.ReplyKeyboard(1))
+	_, err = bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: 1}, Text: "bye", ReplyMarkup: &ReplyKeyboardRemove{RemoveKeyboard: true}})
+	require.NoError(t, err)
+	require.Nil(t, bot.ReplyKeyboard(1))
+
+	var inline *InlineKeyboardMarkup
+	_, err = bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: 1}, Text: "typed nil", ReplyMarkup: inline})
+	require.NoError(t, err)
+}
+
+func TestReplyMarkup_API(t *testing.T) {
+	t.Parallel()
+	bot := newTestBot(t)
+	defer bot.Close(context.Background())
+	conn, _, err := websocket.DefaultDialer.Dial(bot.URL()+"?v=1", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	waitClients(t, bot, 1)
+
+	status, env, _ := callAPI(t, bot, "token", "sendMessage",
+		`{"chat_id":2,"text":"your name?","reply_markup":{"force_reply":true,"input_field_placeholder":"Name"}}`)
+	require.Equal(t, http.StatusOK, status, env.Description)
+	frame := readEnvelope(t, conn)
+	require.JSONEq(t, `{"force_reply":true,"input_field_placeholder":"Name"}`, string(rawField(t, frame.Payload, "reply_markup")))
+
+	resp, err := http.PostForm(bot.APIURL()+"/bottoken/sendMessage", url.Values{
+		"chat_id":      {"2"},
+		"text":         {"pick"},
+		"reply_markup": {`{"keyboard":[[{"text":"A"}]],"resize_keyboard":true}`},
+	})
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	frame = readEnvelope(t, conn)
+	require.JSONEq(t, `{"keyboard":[[{"text":"A"}]],"resize_keyboard":true}`, string(rawField(t, frame.Payload, "reply_markup")))
+	require.Equal(t, "A", bot.ReplyKeyboard(2).Keyboard[0][0].Text)
+
+	status, env, _ = callAPI(t, bot, "token", "sendMessage", `{"chat_id":2,"text":"x","reply_markup":{"foo":1}}`)
+	require.Equal(t, http.StatusBadRequest, status)
+	require.Equal(t, "Bad Request: can't parse reply keyboard markup JSON object", env.Description)
+}
+
+func rawField(t *testing.T, obj json.RawMessage, name string) json.RawMessage {
+	t.Helper()
+	var fields map[string]json.RawMessage
+	require.NoError(t, json.Unmarshal(obj, &fields))
+	return fields[name]
+}
@@ -0,0 +1,290 @@
+package telemock
+
+import (
+	"context"
+	"errors"
+	"unicode/utf8"
+)
+
+type SendPhotoParams struct {
+	BusinessConnectionID    string                   `json:"business_connection_id,omitempty"`
+	ChatID                  ChatID                   `json:"chat_id"`
+	MessageThreadID         int                      `json:"message_thread_id,omitempty"`
+	DirectMessagesTopicID   int64                    `json:"direct_messages_topic_id,omitempty"`
+	Photo                   InputFile                `json:"photo"`
+	Caption                 string                   `json:"caption,omitempty"`
+	ParseMode               string                   `json:"parse_mode,omitempty"`
+	CaptionEntities         []MessageEntity          `json:"caption_entities,omitempty"`
+	ShowCaptionAboveMedia   bool                     `json:"show_caption_above_media,omitempty"`
+	HasSpoiler              bool                     `json:"has_spoiler,omitempty"`
+	DisableNotification     bool                     `json:"disable_notification,omitempty"`
+	ProtectContent          bool                     `json:"protect_content,omitempty"`
+	AllowPaidBroadcast      bool                     `json:"allow_paid_broadcast,omitempty"`
+	MessageEffectID         string                   `json:"message_effect_id,omitempty"`
+	SuggestedPostParameters *SuggestedPostParameters `json:"suggested_post_parameters,omitempty"`
+	ReplyParameters         *ReplyParameters         `json:"reply_parameters,omitempty"`
+	ReplyMarkup             ReplyMarkup              `json:"reply_markup,omitempty"`
+}
+
+type SendDocumentParams struct {
+	BusinessConnectionID        string                   `json:"business_connection_id,omitempty"`
+	ChatID                      ChatID                   `json:"chat_id"`
+	MessageThreadID             int                      `json:"message_thread_id,omitempty"`
+	DirectMessagesTopicID       int64                    `json:"direct_messages_topic_id,omitempty"`
+	Document                    InputFile                `json:"document"`
+	Thumbnail                   *InputFile               `json:"thumbnail,omitempty"`
+	Caption                     string                   `json:"caption,omitempty"`
+	ParseMode                   string                   `json:"parse_mode,omitempty"`
+	CaptionEntities             []MessageEntity          `json:"caption_entities,omitempty"`
+	DisableContentTypeDetection bool                     `json:"disable_content_type_detection,omitempty"`
+	DisableNotification         bool                     `json:"disable_notification,omitempty"`
+	ProtectContent              bool                     `json:"protect_content,omitempty"`
+	AllowPaidBroadcast          bool                     `json:"allow_paid_broadcast,omitempty"`
+	MessageEffectID             string                   `json:"message_effect_id,omitempty"`
+	SuggestedPostParameters     *SuggestedPostParameters `json:"suggested_post_parameters,omitempty"`
+	ReplyParameters             *ReplyParameters         `json:"reply_parameters,omitempty"`
+	ReplyMarkup                 ReplyMarkup              `json:"reply_markup,omitempty"`
+}
+
+type SendVideoParams struct {
+	BusinessConnectionID    string                   `json:"business_connection_id,omitempty"`
+	ChatID                  ChatID                   `json:"chat_id"`
+	MessageThreadID         int                      `json:"message_thread_id,omitempty"`
+	DirectMessagesTopicID   int64                    `json:"direct_messages_topic_id,omitempty"`
+	Video                   InputFile                `json:"video"`
+	Duration                int                      `json:"duration,omitempty"`
+	Width                   int                      `json:"width,omitempty"`
+	Height                  int                      `json:"height,omitempty"`
+	Thumbnail               *InputFile               `json:"thumbnail,omitempty"`
+	Cover                   *InputFile               `json:"cover,omitempty"`
+	StartTimestamp          int                      `json:"start_timestamp,omitempty"`
+	Caption                 string                   `json:"caption,omitempty"`
+	ParseMode               string                   `json:"parse_mode,omitempty"`
+	CaptionEntities         []MessageEntity          `json:"caption_entities,omitempty"`
+	ShowCaptionAboveMedia   bool                     `json:"show_caption_above_media,omitempty"`
+	HasSpoiler              bool                     `json:"has_spoiler,omitempty"`
+	SupportsStreaming       bool                     `json:"supports_streaming,omitempty"`
+	DisableNotification     bool                     `json:"disable_notification,omitempty"`
+	ProtectContent          bool                     `json:"protect_content,omitempty"`
+	AllowPaidBroadcast      bool                     `json:"allow_paid_broadcast,omitempty"`
+	MessageEffectID         string                   `json:"message_effect_id,omitempty"`
+	SuggestedPostParameters *SuggestedPostParameters `json:"suggested_post_parameters,omitempty"`
+	ReplyParameters         *ReplyParameters         `json:"reply_parameters,omitempty"`
+	ReplyMarkup             ReplyMarkup              `json:"reply_markup,omitempty"`
+}
+
+type SendAudioParams struct {
+	BusinessConnectionID    string                   `json:"business_connection_id,omitempty"`
+	ChatID                  ChatID                   `json:"chat_id"`
+	MessageThreadID         int                      `json:"message_thread_id,omitempty"`
+	DirectMessagesTopicID   int64                    `json:"direct_messages_topic_id,omitempty"`
+	Audio                   InputFile                `json:"audio"`
+	Caption                 string                   `json:"caption,omitempty"`
+	ParseMode               string                   `json:"parse_mode,omitempty"`
+	CaptionEntities         []MessageEntity          `json:"caption_entities,omitempty"`
+	Duration                int                      `json:"duration,omitempty"`
+	Performer               string                   `json:"performer,omitempty"`
+	Title                   string                   `json:"title,omitempty"`
+	Thumbnail               *InputFile               `json:"thumbnail,omitempty"`
+	DisableNotification     bool                     `json:"disable_notification,omitempty"`
+	ProtectContent          bool                     `json:"protect_content,omitempty"`
+	AllowPaidBroadcast      bool                     `json:"allow_paid_broadcast,omitempty"`
+	MessageEffectID         string                   `json:"message_effect_id,omitempty"`
+	SuggestedPostParameters *SuggestedPostParameters `json:"suggested_post_parameters,omitempty"`
+	ReplyParameters         *ReplyParameters         `json:"reply_parameters,omitempty"`
+	ReplyMarkup             ReplyMarkup              `json:"reply_markup,omitempty"`
+}
+
+type SendVoiceParams struct {
+	BusinessConnectionID    string                   `json:"business_connection_id,omitempty"`
+	ChatID                  ChatID                   `json:"chat_id"`
+	MessageThreadID         int                      `json:"message_thread_id,omitempty"`
+	DirectMessagesTopicID   int64                    `json:"direct_messages_topic_id,omitempty"`
+	Voice                   InputFile                `json:"voice"`
+	Caption                 string                   `json:"caption,omitempty"`
+	ParseMode               string                   `json:"parse_mode,omitempty"`
+	CaptionEntities         []MessageEntity          `json:"caption_entities,omitempty"`
+	Duration                int                      `json:"duration,omitempty"`
+	DisableNotification     bool                     `json:"disable_notification,omitempty"`
+	ProtectContent          bool                     `json:"protect_content,omitempty"`
+	AllowPaidBroadcast      bool                     `json:"allow_paid_broadcast,omitempty"`
+	MessageEffectID         string                   `json:"message_effect_id,omitempty"`
+	SuggestedPostParameters *SuggestedPostParameters `json:"suggested_post_parameters,omitempty"`
+	ReplyParameters         *ReplyParameters         `json:"reply_parameters,omitempty"`
+	ReplyMarkup             ReplyMarkup              `json:"reply_markup,omitempty"`
+}
+
+type SendAnimationParams struct {
+	BusinessConnectionID    string                   `json:"business_connection_id,omitempty"`
+	ChatID                  ChatID                   `json:"chat_id"`
+	MessageThreadID         int                      `json:"message_thread_id,omitempty"`
+	DirectMessagesTopicID   int64                    `json:"direct_messages_topic_id,omitempty"`
+	Animation               InputFile                `json:"animation"`
+	Duration                int                      `json:"duration,omitempty"`
+	Width                   int                      `json:"width,omitempty"`
+	Height                  int                      `json:"height,omitempty"`
+	Thumbnail               *InputFile               `json:"thumbnail,omitempty"`
+	Caption                 string                   `json:"caption,omitempty"`
+	ParseMode               string                   `json:"parse_mode,omitempty"`
+	CaptionEntities         []MessageEntity          `json:"caption_entities,omitempty"`
+	ShowCaptionAboveMedia   bool                     `json:"show_caption_above_media,omitempty"`
+	HasSpoiler              bool                     `json:"has_spoiler,omitempty"`
+	DisableNotification     bool                     `json:"disable_notification,omitempty"`
+	ProtectContent          bool                     `json:"protect_content,omitempty"`
+	AllowPaidBroadcast      bool                     `json:"allow_paid_broadcast,omitempty"`
+	MessageEffectID         string                   `json:"message_effect_id,omitempty"`
+	SuggestedPostParameters *SuggestedPostParameters `json:"suggested_post_parameters,omitempty"`
+	ReplyParameters         *ReplyParameters         `json:"reply_parameters,omitempty"`
+	ReplyMarkup             ReplyMarkup              `json:"reply_markup,omitempty"`
+}
+
+// maxCaptionLength is the Bot API limit on captions, in characters
+const maxCaptionLength = 1024
+
+// mediaMessage is what every media send method has in common
+type mediaMessage struct {
+	chatID   ChatID
+	kind     string
+	file     InputFile
+	caption  string
+	entities []MessageEntity
+	reply    *ReplyParameters
+	markup   ReplyMarkup
+}
+
+// SendPhoto sends an image. GIF, JPEG and PNG uploads report their real size.
+func (b *Bot) SendPhoto(ctx context.Context, params *SendPhotoParams) (*Message, error) {
+	if params == nil {
+		return nil, errors.New("nil params")
+	}
+	return b.sendMedia(mediaMessage{params.ChatID, "photo", params.Photo, params.Caption,
+		params.CaptionEntities, params.ReplyParameters, params.ReplyMarkup},
+		func(m *Message, f *storedFile) {
+			w, h := imageSize(f.data)
+			m.Photo = []PhotoSize{{FileID: f.id, FileUniqueID: f.uniqueID, Width: w, Height: h, FileSize: int(f.size())}}
+		})
+}
+
+// SendDocument sends a general file.
+func (b *Bot) SendDocument(ctx context.Context, params *SendDocumentParams) (*Message, error) {
+	if params == nil {
+		return nil, errors.New("nil params")
+	}
+	return b.sendMedia(mediaMessage{params.ChatID, "document", params.Document, params.Caption,
+		params.CaptionEntities, params.ReplyParameters, params.ReplyMarkup},
+		func(m *Message, f *storedFile) {
+			m.Document = documentOf(f)
+		})
+}
+
+// SendVideo sends a video; the metadata comes from params as telemock doesn't
+// decode videos.
+func (b *Bot) SendVideo(ctx context.Context, params *SendVideoParams) (*Message, error) {
+	if params == nil {
+		return nil, errors.New("nil params")
+	}
+	return b.sendMedia(mediaMessage{params.ChatID, "video", params.Video, params.Caption,
+		params.CaptionEntities, params.ReplyParameters, params.ReplyMarkup},
+		func(m *Message, f *storedFile) {
+			m.Video = &Video{FileID: f.id, FileUniqueID: f.uniqueID, Width: params.Width, Height: params.Height,
+				Duration: params.Duration, FileName: f.name, MimeType: f.mimeType, FileSize: f.size()}
+		})
+}
+
+// SendAudio sends a music file.
+func (b *Bot) SendAudio(ctx context.Context, params *SendAudioParams) (*Message, error) {
+	if params == nil {
+		return nil, errors.New("nil params")
+	}
+	return b.sendMedia(mediaMessage{params.ChatID, "audio", params.Audio, params.Caption,
+		params.CaptionEntities, params.ReplyParameters, params.ReplyMarkup},
+		func(m *Message, f *storedFile) {
+			m.Audio = &Audio{FileID: f.id, FileUniqueID: f.uniqueID, Duration: params.Duration,
+				Performer: params.Performer, Title: params.Title, FileName: f.name, MimeType: f.mimeType, FileSize: f.size()}
+		})
+}
+
+// SendVoice sends a voice note.
+func (b *Bot) SendVoice(ctx context.Context, params *SendVoiceParams) (*Message, error) {
+	if params == nil {
+		return nil, errors.New("nil params")
+	}
+	return b.sendMedia(mediaMessage{params.ChatID, "voice", params.Voice, params.Caption,
+		params.CaptionEntities, params.ReplyParameters, params.ReplyMarkup},
+		func(m *Message, f *storedFile) {
+			m.Voice = &Voice{FileID: f.id, FileUniqueID: f.uniqueID, Duration: params.Duration,
+				MimeType: f.mimeType, FileSize: f.size()}
+		})
+}
+
+// SendAnimation sends a GIF or a silent video. Like Telegram, the message
+// carries the file both as Animation and as Document.
+func (b *Bot) SendAnimation(ctx context.Context, params *SendAnimationParams) (*Message, error) {
+	if params == nil {
+		return nil, errors.New("nil params")
+	}
+	return b.sendMedia(mediaMessage{params.ChatID, "animation", params.Animation, params.Caption,
+		params.CaptionEntities, params.ReplyParameters, params.ReplyMarkup},
+		func(m *Message, f *storedFile) {
+			w, h := params.Width, params.Height
+			if w == 0 && h == 0 {
+				w, h = imageSize(f.data)
+			}
+			m.Animation = &Animation{FileID: f.id, FileUniqueID: f.uniqueID, Width: w, Height: h,
+				Duration: params.Duration, FileName: f.name, MimeType: f.mimeType, FileSize: f.size()}
+			m.Document = documentOf(f)
+		})
+}
+
+func documentOf(f *storedFile) *Document {
+	return &Document{FileID: f.id, FileUniqueID: f.uniqueID, FileName: f.name, MimeType: f.mimeType, FileSize: f.size()}
+}
+
+// sendMedia validates a media message, stores its file and delivers it; attach
+// puts the file into the message
+func (b *Bot) sendMedia(mm mediaMessage, attach func(*Message, *storedFile)) (*Message, error) {
+	if b.isClosed() {
+		return nil, ErrBotClosed
+	}
+	if mm.chatID.ID == 0 {
+		return nil, errBadRequest("chat not found")
+	}
+	if utf8.RuneCountInString(mm.caption) > maxCaptionLength {
+		return nil, errBadRequest("message caption is too long")
+	}
+	if err := validateInlineKeyboard(mm.markup); err != nil {
+		return nil, err
+	}
+	message, err := b.newMessage(mm.chatID, mm.reply, mm.markup)
+	if err != nil {
+		return nil, err
+	}
+	// файл сохраняем, только когда сообщение точно можно отправить
+	f, err := b.resolveFile(mm.kind, mm.file)
+	if err != nil {
+		return nil, err
+	}
+	message.Caption, message.CaptionEntities = mm.caption, mm.entities
+	attach(message, f)
+	return b.deliver(message, mm.markup)
+}
+
+// mediaOf returns the kind and file_id of a message's media, or empty strings
+// for text
+func mediaOf(m *Message) (kind, fileID string) {
+	switch {
+	case len(m.Photo) > 0:
+		return "photo", m.Photo[len(m.Photo)-1].FileID
+	case m.Animation != nil:
+		return "animation", m.Animation.FileID
+	case m.Video != nil:
+		return "video", m.Video.FileID
+	case m.Audio != nil:
+		return "audio", m.Audio.FileID
+	case m.Voice != nil:
+		return "voice", m.Voice.FileID
+	case m.Document != nil:
+		return "document", m.Document.FileID
+	}
+	return "", ""
+}
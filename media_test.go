@@ -0,0 +1,160 @@
+package telemock
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testPNG(t *testing.T, w, h int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, w, h))))
+	return buf.Bytes()
+}
+
+func TestSendPhoto(t *testing.T) {
+	t.Parallel()
+	bot := newTestBot(t)
+	defer bot.Close(context.Background())
+	ctx := context.Background()
+	data := testPNG(t, 3, 2)
+
+	msg, err := bot.SendPhoto(ctx, &SendPhotoParams{
+		ChatID:  ChatID{ID: 7},
+		Photo:   InputFile{File: NameReader(bytes.NewReader(data), "dir/cat.png")},
+		Caption: "a cat",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "a cat", msg.Caption)
+	require.Len(t, msg.Photo, 1)
+	photo := msg.Photo[0]
+	require.Equal(t, 3, photo.Width)
+	require.Equal(t, 2, photo.Height)
+	require.Equal(t, len(data), photo.FileSize)
+
+	ev := <-bot.Outbox()
+	require.Equal(t, bot.APIURL()+"/file/bottoken/photos/file_1.png", ev.FileURL)
+	resp, err := http.Get(ev.FileURL)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.Equal(t, "image/png", resp.Header.Get("Content-Type"))
+	require.Equal(t, data, body)
+
+	resp, err = http.Get(bot.APIURL() + "/file/botwrong/photos/file_1.png")
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// the file_id of a sent file can be sent again, as the same kind only
+	again, err := bot.SendPhoto(ctx, &SendPhotoParams{ChatID: ChatID{ID: 8}, Photo: InputFile{FileID: photo.FileID}})
+	require.NoError(t, err)
+	require.Equal(t, photo.FileUniqueID, again.Photo[0].FileUniqueID)
+	_, err = bot.SendDocument(ctx, &SendDocumentParams{ChatID: ChatID{ID: 8}, Document: InputFile{FileID: photo.FileID}})
+	require.EqualError(t, err, `telemock: api: 400 "Bad Request: wrong file type"`)
+}
+
+func TestSendMedia_Errors(t *testing.T) {
+	t.Parallel()
+	bot, err := NewBot("token", WithoutListener(), WithoutLogFile(), WithLogger(nil))
+	require.NoError(t, err)
+	defer bot.Close(context.Background())
+	ctx := context.Background()
+
+	_, err = bot.SendVoice(ctx, &SendVoiceParams{ChatID: ChatID{ID: 1}})
+	require.EqualError(t, err, `telemock: api: 400 "Bad Request: there is no voice in the request"`)
+	_, err = bot.SendPhoto(ctx, &SendPhotoParams{ChatID: ChatID{ID: 1}, Photo: InputFile{FileID: "nope"}})
+	require.ErrorIs(t, err, errWrongFile)
+	_, err = bot.SendAudio(ctx, &SendAudioParams{
+		ChatID:  ChatID{ID: 1},
+		Audio:   InputFile{URL: "https://example.com/song.mp3"},
+		Caption: strings.Repeat("я", maxCaptionLength+1),
+	})
+	require.EqualError(t, err, `telemock: api: 400 "Bad Request: message caption is too long"`)
+
+	// remote files are referenced, not fetched
+	msg, err := bot.SendAudio(ctx, &SendAudioParams{
+		ChatID: ChatID{ID: 1}, Audio: InputFile{URL: "https://example.com/song.mp3"}, Title: "Song",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "song.mp3", msg.Audio.FileName)
+	require.Equal(t, "audio/mpeg", msg.Audio.MimeType)
+	ev := <-bot.Outbox()
+	require.Equal(t, "https://example.com/song.mp3", ev.FileURL)
+}
+
+func TestSendMedia_LocalFiles(t *testing.T) {
+	t.Parallel()
+	dir, outside := t.TempDir(), t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "a.txt"), []byte("inside"), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(outside, "b.txt"), []byte("secret"), 0o600))
+	require.NoError(t, os.Symlink(filepath.Join(outside, "b.txt"), filepath.Join(dir, "link.txt")))
+	ctx := context.Background()
+	send := func(bot *Bot, name string) (*Message, error) {
+		return bot.SendDocument(ctx, &SendDocumentParams{ChatID: ChatID{ID: 1}, Document: InputFile{URL: "file://" + name}})
+	}
+
+	bot, err := NewBot("token", WithoutListener(), WithoutLogFile(), WithLogger(nil))
+	require.NoError(t, err)
+	defer bot.Close(context.Background())
+	_, err = send(bot, filepath.Join(dir, "a.txt"))
+	require.ErrorIs(t, err, errWrongFile)
+
+	bot, err = NewBot("token", WithoutListener(), WithoutLogFile(), WithLogger(nil), WithLocalFiles(dir))
+	require.NoError(t, err)
+	defer bot.Close(context.Background())
+	msg, err := send(bot, filepath.Join(dir, "a.txt"))
+	require.NoError(t, err)
+	require.Equal(t, int64(len("inside")), msg.Document.FileSize)
+	for _, name := range []string{filepath.Join(outside, "b.txt"), filepath.Join(dir, "..", filepath.Base(outside), "b.txt"),
+		filepath.Join(dir, "link.txt")} {
+		_, err = send(bot, name)
+		require.ErrorIs(t, err, errWrongFile, name)
+	}
+
+	// a failed send leaves no upload behind
+	require.NoError(t, bot.CreateChat(Chat{ID: -100, Type: ChatTypeGroup, Title: "Team"},
+		&ChatMemberLeft{Status: MemberStatusLeft, User: bot.me}))
+	_, err = bot.SendDocument(ctx, &SendDocumentParams{ChatID: ChatID{ID: -100},
+		Document: InputFile{File: NameReader(strings.NewReader("x"), "x.txt")}})
+	require.EqualError(t, err, `telemock: api: 403 "Forbidden: bot is not a member of the group chat"`)
+	require.Len(t, bot.files.byID, 1)
+}
+
+func TestAPI_SendPhotoMultipart(t *testing.T) {
+	t.Parallel()
+	bot := newTestBot(t)
+	defer bot.Close(context.Background())
+
+	// the request telego sends for SendPhoto with an uploaded file
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	require.NoError(t, mw.WriteField("chat_id", "5"))
+	require.NoError(t, mw.WriteField("caption", "hi"))
+	require.NoError(t, mw.WriteField("photo", ""))
+	fw, err := mw.CreateFormFile("photo", "dot.png")
+	require.NoError(t, err)
+	_, err = fw.Write(testPNG(t, 1, 1))
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+
+	resp, err := http.Post(bot.APIURL()+"/bottoken/sendPhoto", mw.FormDataContentType(), &body)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	ev := <-bot.Outbox()
+	require.Equal(t, "hi", ev.Message.Caption)
+	require.Equal(t, 1, ev.Message.Photo[0].Width)
+}
@@ -0,0 +1,222 @@
+package telemock
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"path/filepath"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// BotOption configures a Bot created by NewBot. Options are applied in order.
+type BotOption func(b *Bot) error
+
+// WithAddr sets the listen address. Use ":0" to pick an ephemeral port.
+func WithAddr(addr string) BotOption {
+	return func(b *Bot) error {
+		if addr == "" {
+			return errors.New("telemock: empty listen address")
+		}
+		b.addr = addr
+		return nil
+	}
+}
+
+// WithoutListener runs the bot purely in memory: no port is opened, updates
+// are fed with Bot.Inject and sent messages are read from Bot.Outbox.
+func WithoutListener() BotOption {
+	return func(b *Bot) error {
+		b.inMemory = true
+		return nil
+	}
+}
+
+// WithLogDir sets the directory for JSONL request logs. An empty dir disables logging.
+func WithLogDir(dir string) BotOption {
+	return func(b *Bot) error {
+		b.logDir = dir
+		return nil
+	}
+}
+
+// WithoutLogFile disables JSONL request logging entirely.
+func WithoutLogFile() BotOption {
+	return WithLogDir("")
+}
+
+// WithUpdatesBuffer sets the capacity of the updates channel.
+func WithUpdatesBuffer(size int) BotOption {
+	return func(b *Bot) error {
+		if size < 0 {
+			return errors.New("telemock: negative updates buffer size")
+		}
+		b.updatesBuf = size
+		return nil
+	}
+}
+
+// WithClientQueue sets how many outbound frames each websocket client may have
+// queued and what happens when that queue is full.
+func WithClientQueue(size int, policy SlowClientPolicy) BotOption {
+	return func(b *Bot) error {
+		if size < 0 {
+			return errors.New("telemock: negative client queue size")
+		}
+		b.clientQ = size
+		b.slowPolicy = policy
+		return nil
+	}
+}
+
+// OverflowPolicy decides what happens to an incoming update when the updates
+// buffer is full because the bot does not keep up.
+type OverflowPolicy int
+
+const (
+	// OverflowDropOldest discards the oldest buffered update. This is the default.
+	OverflowDropOldest OverflowPolicy = iota
+	// OverflowBlock makes the sender wait, which applies backpressure to the
+	// websocket reader. No update is lost.
+	OverflowBlock
+	// OverflowDropNewest discards the incoming update. Websocket clients get
+	// an error frame; Inject returns nil.
+	OverflowDropNewest
+	// OverflowReject discards the incoming update and reports it: websocket
+	// clients get an error frame, Inject returns ErrUpdatesFull.
+	OverflowReject
+)
+
+// WithUpdateOverflow sets the policy applied when the updates buffer is full.
+func WithUpdateOverflow(policy OverflowPolicy) BotOption {
+	return func(b *Bot) error {
+		b.overflow = policy
+		return nil
+	}
+}
+
+// WithOutboxBuffer sets how many events Bot.Outbox holds before the oldest
+// are discarded. It must hold at least one.
+func WithOutboxBuffer(size int) BotOption {
+	return func(b *Bot) error {
+		if size < 1 {
+			return errors.New("telemock: outbox buffer must hold at least one event")
+		}
+		b.outboxBuf = size
+		return nil
+	}
+}
+
+// WithLogger sets the logger used for diagnostics. A nil logger discards output.
+func WithLogger(l *log.Logger) BotOption {
+	return func(b *Bot) error {
+		if l == nil {
+			l = log.New(io.Discard, "", 0)
+		}
+		b.logger = l
+		return nil
+	}
+}
+
+// WithSlogLogger routes diagnostics to a structured logger at info level.
+func WithSlogLogger(l *slog.Logger) BotOption {
+	return func(b *Bot) error {
+		if l == nil {
+			return errors.New("telemock: nil slog logger")
+		}
+		b.logger = slog.NewLogLogger(l.Handler(), slog.LevelInfo)
+		return nil
+	}
+}
+
+// WithUpgrader replaces the default websocket upgrader, which accepts any origin.
+func WithUpgrader(u websocket.Upgrader) BotOption {
+	return func(b *Bot) error {
+		b.upgrader = u
+		return nil
+	}
+}
+
+// WithClock replaces the wall clock the bot tells the time by, e.g. with a
+// MockClock.
+func WithClock(c Clock) BotOption {
+	return func(b *Bot) error {
+		if c == nil {
+			return errors.New("telemock: nil clock")
+		}
+		b.clock = c
+		return nil
+	}
+}
+
+// WithCallbackTimeout sets how long the bot has to answer a callback query
+// before AnswerCallbackQuery fails with "query is too old". The default is 15s.
+func WithCallbackTimeout(d time.Duration) BotOption {
+	return func(b *Bot) error {
+		if d <= 0 {
+			return errors.New("telemock: non-positive callback timeout")
+		}
+		b.cbTimeout = d
+		return nil
+	}
+}
+
+// WithLocalFiles lets the bot send files from dir by file:// URLs, like the
+// local Bot API server does. Paths outside dir are refused, and so are all
+// file:// URLs without this option.
+func WithLocalFiles(dir string) BotOption {
+	return func(b *Bot) error {
+		if dir == "" {
+			return errors.New("telemock: empty local files directory")
+		}
+		abs, err := filepath.Abs(dir)
+		if err != nil {
+			return fmt.Errorf("telemock: local files directory: %w", err)
+		}
+		b.localDir = abs
+		return nil
+	}
+}
+
+// longPolling holds the settings of UpdatesViaLongPolling
+type longPolling struct {
+	updateChanBuffer uint
+	updateInterval   time.Duration
+	retryTimeout     time.Duration
+}
+
+// LongPollingOption configures UpdatesViaLongPolling, as in telego.
+type LongPollingOption func(lp *longPolling) error
+
+// WithLongPollingUpdateInterval is accepted for compatibility with telego.
+func WithLongPollingUpdateInterval(updateInterval time.Duration) LongPollingOption {
+	return func(lp *longPolling) error {
+		if updateInterval < 0 {
+			return fmt.Errorf("update interval is negative: %s", updateInterval)
+		}
+		lp.updateInterval = updateInterval
+		return nil
+	}
+}
+
+// WithLongPollingRetryTimeout is accepted for compatibility with telego.
+func WithLongPollingRetryTimeout(retryTimeout time.Duration) LongPollingOption {
+	return func(lp *longPolling) error {
+		if retryTimeout < 0 {
+			return fmt.Errorf("retry timeout is negative: %s", retryTimeout)
+		}
+		lp.retryTimeout = retryTimeout
+		return nil
+	}
+}
+
+// WithLongPollingBuffer sets the buffer size of the updates channel.
+func WithLongPollingBuffer(chanBuffer uint) LongPollingOption {
+	return func(lp *longPolling) error {
+		lp.updateChanBuffer = chanBuffer
+		return nil
+	}
+}
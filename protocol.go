@@ -0,0 +1,303 @@
+package telemock
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// ProtocolVersion is the websocket protocol version described in PROTOCOL.md.
+// Frames without a "type" field are the legacy v0 format.
+const ProtocolVersion = 1
+
+// envelope is a v1 frame in either direction. ID is chosen by the client and
+// echoed in the ack or error answering the frame.
+type envelope struct {
+	V       int             `json:"v,omitempty"`
+	Type    string          `json:"type"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+type outEnvelope struct {
+	V       int             `json:"v"`
+	Type    string          `json:"type"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Payload any             `json:"payload,omitempty"`
+}
+
+func newEnvelope(typ string, id json.RawMessage, payload any) outEnvelope {
+	return outEnvelope{V: ProtocolVersion, Type: typ, ID: id, Payload: payload}
+}
+
+// messageFrame is the payload of "message" and "edit" frames. A message
+// carries text or one kind of media.
+type messageFrame struct {
+	ChatID    int64      `json:"chat_id"`
+	FromID    int64      `json:"from_id,omitempty"` // sender in groups and channels
+	MessageID int        `json:"message_id,omitempty"`
+	Text      string     `json:"text"`
+	Caption   string     `json:"caption,omitempty"`
+	Photo     *fileFrame `json:"photo,omitempty"`
+	Document  *fileFrame `json:"document,omitempty"`
+	Voice     *fileFrame `json:"voice,omitempty"`
+	Sticker   *fileFrame `json:"sticker,omitempty"`
+	Location  *Location  `json:"location,omitempty"`
+	Contact   *Contact   `json:"contact,omitempty"`
+	// a reply: reply_to_message_id with an optional quote, or the Bot API's
+	// reply_parameters for replies to other chats
+	ReplyToMessageID int              `json:"reply_to_message_id,omitempty"`
+	Quote            string           `json:"quote,omitempty"`
+	ReplyParameters  *ReplyParameters `json:"reply_parameters,omitempty"`
+}
+
+// reply returns the reply parameters of the message, if it is a reply
+func (p *messageFrame) reply() *ReplyParameters {
+	if p.ReplyParameters != nil {
+		return p.ReplyParameters
+	}
+	if p.ReplyToMessageID == 0 {
+		return nil
+	}
+	return &ReplyParameters{MessageID: p.ReplyToMessageID, Quote: p.Quote}
+}
+
+// callbackFrame is the payload of a "callback" frame: a pressed inline button.
+type callbackFrame struct {
+	ChatID    int64  `json:"chat_id"`
+	FromID    int64  `json:"from_id,omitempty"`
+	MessageID int    `json:"message_id"`
+	Data      string `json:"data"`
+}
+
+// pressFrame is the payload of a "press" frame: an inline button of a bot
+// message, found by its text.
+type pressFrame struct {
+	ChatID    int64  `json:"chat_id"`
+	FromID    int64  `json:"from_id,omitempty"`
+	MessageID int    `json:"message_id"`
+	Text      string `json:"text"`
+}
+
+// chatFrame is the payload of a "chat" frame: a new group or channel.
+type chatFrame struct {
+	Chat
+	Members []memberFrame `json:"members,omitempty"`
+}
+
+// memberFrame is the payload of "join", "leave" and "member" frames, and a
+// member of a new chat.
+type memberFrame struct {
+	ChatID int64  `json:"chat_id,omitempty"`
+	User   User   `json:"user"`
+	Status string `json:"status,omitempty"`
+}
+
+// subscribeFrame is the payload of "subscribe" and "unsubscribe" frames.
+type subscribeFrame struct {
+	ChatIDs  []int64 `json:"chat_ids"`
+	Observer bool    `json:"observer,omitempty"`
+}
+
+type ackPayload struct {
+	Version         int    `json:"version,omitempty"`
+	UpdateID        int    `json:"update_id,omitempty"`
+	MessageID       int    `json:"message_id,omitempty"`
+	CallbackQueryID string `json:"callback_query_id,omitempty"`
+	Action          string `json:"action,omitempty"` // what a pressed button does
+	URL             string `json:"url,omitempty"`
+	Query           string `json:"query,omitempty"`
+	CopyText        string `json:"copy_text,omitempty"`
+}
+
+type errorPayload struct {
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+}
+
+// handleEnvelope processes a v1 frame and answers it with an ack or an error.
+// Any v1 frame switches the connection to v1 for frames sent by the server.
+// It reports whether the read loop should continue.
+func (b *Bot) handleEnvelope(c *client, env envelope) bool {
+	if env.V != 0 && env.V != ProtocolVersion {
+		b.sendError(c, env.ID, 400, "Bad Request: unsupported protocol version")
+		return true
+	}
+	c.setProtocol(ProtocolVersion)
+
+	badRequest := func(desc string) bool {
+		b.sendError(c, env.ID, 400, "Bad Request: "+desc)
+		return true
+	}
+	decode := func(dst any) bool {
+		if len(env.Payload) == 0 {
+			return false
+		}
+		return json.Unmarshal(env.Payload, dst) == nil
+	}
+
+	var upd Update
+	var uploaded *storedFile // a client file to drop if the message fails
+	switch env.Type {
+	case "hello":
+		b.sendFrame(c, newEnvelope("ack", env.ID, ackPayload{Version: ProtocolVersion}))
+		return true
+	case "subscribe", "unsubscribe":
+		var p subscribeFrame
+		if !decode(&p) {
+			return badRequest("invalid " + env.Type + " payload")
+		}
+		switch {
+		case env.Type == "unsubscribe":
+			c.unsubscribe(p.ChatIDs...)
+		case p.Observer:
+			c.setObserver()
+		default:
+			c.subscribe(p.ChatIDs...)
+		}
+		b.sendFrame(c, newEnvelope("ack", env.ID, ackPayload{}))
+		return true
+	case "message", "edit":
+		var p messageFrame
+		switch {
+		case !decode(&p):
+			return badRequest("invalid " + env.Type + " payload")
+		case p.ChatID == 0:
+			return badRequest("chat_id is empty")
+		case env.Type == "edit" && p.hasMedia():
+			return badRequest("only text can be edited")
+		case p.Text == "" && !p.hasMedia():
+			return badRequest("message text is empty")
+		case env.Type == "edit" && p.MessageID == 0:
+			return badRequest("message_id is empty")
+		}
+		var err error
+		if env.Type == "edit" {
+			upd, err = b.editUpdate(p.ChatID, p.FromID, p.MessageID, p.Text)
+		} else if upd, err = b.textUpdate(p.ChatID, p.FromID, p.MessageID, p.Text); err == nil {
+			if uploaded, err = b.attachMedia(upd.message(), &p); err == nil {
+				err = b.attachReply(upd.message(), p.reply())
+			}
+		}
+		if err != nil {
+			b.files.remove(uploaded)
+			b.sendAPIError(c, env.ID, err)
+			return true
+		}
+		c.follow(p.ChatID)
+	case "callback":
+		var p callbackFrame
+		switch {
+		case !decode(&p):
+			return badRequest("invalid callback payload")
+		case p.ChatID == 0:
+			return badRequest("chat_id is empty")
+		case p.Data == "":
+			return badRequest("callback data is empty")
+		}
+		var err error
+		if upd, err = b.callbackUpdate(p.ChatID, p.FromID, p.MessageID, "", p.Data); err != nil {
+			b.sendAPIError(c, env.ID, err)
+			return true
+		}
+		c.follow(p.ChatID)
+	case "press":
+		var p pressFrame
+		switch {
+		case !decode(&p):
+			return badRequest("invalid press payload")
+		case p.ChatID == 0:
+			return badRequest("chat_id is empty")
+		case p.Text == "":
+			return badRequest("button text is empty")
+		}
+		c.follow(p.ChatID)
+		res, err := b.pressButton(p.ChatID, p.FromID, p.MessageID, p.Text)
+		if err != nil {
+			b.sendAPIError(c, env.ID, err)
+			return true
+		}
+		ack := ackPayload{Action: res.Action, URL: res.URL, Query: res.Query, CopyText: res.CopyText}
+		if res.Update != nil {
+			if err := b.deliverFromClient(c, env.ID, *res.Update); err != nil {
+				return !errors.Is(err, ErrBotClosed)
+			}
+			ack.UpdateID, ack.CallbackQueryID = res.Update.UpdateID, res.Update.CallbackQuery.ID
+		}
+		b.sendFrame(c, newEnvelope("ack", env.ID, ack))
+		return true
+	case "user":
+		var p User
+		if !decode(&p) {
+			return badRequest("invalid user payload")
+		}
+		if err := b.SetUserProfile(p); err != nil {
+			b.sendAPIError(c, env.ID, err)
+			return true
+		}
+		b.sendFrame(c, newEnvelope("ack", env.ID, ackPayload{}))
+		return true
+	case "chat", "join", "leave", "member":
+		if err := b.handleChatFrame(c, env.Type, decode); err != nil {
+			b.sendAPIError(c, env.ID, err)
+			return true
+		}
+		b.sendFrame(c, newEnvelope("ack", env.ID, ackPayload{}))
+		return true
+	default:
+		return badRequest("unknown frame type " + env.Type)
+	}
+
+	if err := b.deliverFromClient(c, env.ID, upd); err != nil {
+		b.files.remove(uploaded)
+		return !errors.Is(err, ErrBotClosed)
+	}
+	ack := ackPayload{UpdateID: upd.UpdateID}
+	if msg := upd.message(); msg != nil {
+		ack.MessageID = msg.MessageID
+	} else if upd.CallbackQuery != nil {
+		ack.CallbackQueryID = upd.CallbackQuery.ID
+	}
+	b.sendFrame(c, newEnvelope("ack", env.ID, ack))
+	return true
+}
+
+// handleChatFrame creates a group or channel, or changes one of its members
+func (b *Bot) handleChatFrame(c *client, typ string, decode func(any) bool) error {
+	if typ == "chat" {
+		var p chatFrame
+		if !decode(&p) {
+			return errBadRequest("invalid chat payload")
+		}
+		members := make([]ChatMember, 0, len(p.Members))
+		for _, mf := range p.Members {
+			m, err := newMember(mf.Status, mf.User)
+			if err != nil {
+				return errBadRequest(err.Error())
+			}
+			members = append(members, m)
+		}
+		c.follow(p.ID)
+		return b.CreateChat(p.Chat, members...)
+	}
+	var p memberFrame
+	switch {
+	case !decode(&p):
+		return errBadRequest("invalid " + typ + " payload")
+	case p.ChatID == 0:
+		return errBadRequest("chat_id is empty")
+	case p.User.ID == 0:
+		return errBadRequest("user id is empty")
+	}
+	c.follow(p.ChatID)
+	switch typ {
+	case "join":
+		return b.AddChatMember(p.ChatID, p.User)
+	case "leave":
+		return b.RemoveChatMember(p.ChatID, p.User.ID)
+	}
+	m, err := newMember(p.Status, p.User)
+	if err != nil {
+		return errBadRequest(err.Error())
+	}
+	return b.SetChatMember(p.ChatID, m)
+}
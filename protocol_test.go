@@ -0,0 +1,164 @@
+package telemock
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+// readEnvelope reads one v1 frame with a timeout
+func readEnvelope(t *testing.T, conn *websocket.Conn) envelope {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	_, raw, err := conn.ReadMessage()
+	require.NoError(t, err)
+	var env envelope
+	require.NoError(t, json.Unmarshal(raw, &env))
+	require.Equal(t, ProtocolVersion, env.V)
+	return env
+}
+
+func TestProtocolV1_AcksAndErrors(t *testing.T) {
+	t.Parallel()
+	bot := newTestBot(t)
+	defer bot.Close(context.Background())
+	conn := dialWS(t, bot)
+	defer conn.Close()
+
+	updates, err := bot.UpdatesViaLongPolling(context.Background(), &GetUpdatesParams{})
+	require.NoError(t, err)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage,
+		[]byte(`{"v":1,"type":"message","id":"m1","payload":{"chat_id":5,"text":"/start"}}`)))
+	env := readEnvelope(t, conn)
+	require.Equal(t, "ack", env.Type)
+	require.JSONEq(t, `"m1"`, string(env.ID))
+	var ack ackPayload
+	require.NoError(t, json.Unmarshal(env.Payload, &ack))
+
+	upd := <-updates
+	require.Equal(t, ack.UpdateID, upd.UpdateID)
+	require.Equal(t, ack.MessageID, upd.Message.MessageID)
+	require.Equal(t, "bot_command", upd.Message.Entities[0].Type)
+
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage,
+		[]byte(fmt.Sprintf(`{"type":"edit","id":2,"payload":{"chat_id":5,"message_id":%d,"text":"fixed"}}`, ack.MessageID))))
+	require.Equal(t, "ack", readEnvelope(t, conn).Type)
+	upd = <-updates
+	require.Equal(t, "fixed", upd.EditedMessage.Text)
+
+	sent, err := bot.SendMessage(context.Background(), &SendMessageParams{ChatID: ChatID{ID: 5}, Text: "hi"})
+	require.NoError(t, err)
+	require.Equal(t, "message", readEnvelope(t, conn).Type)
+
+	for _, tc := range []struct{ frame, want string }{
+		{`{"type":"edit","id":6,"payload":{"chat_id":5,"message_id":999,"text":"x"}}`, "Bad Request: message to edit not found"},
+		{fmt.Sprintf(`{"type":"edit","id":7,"payload":{"chat_id":5,"message_id":%d,"text":"x"}}`, sent.MessageID),
+			"Bad Request: message can't be edited"},
+		{fmt.Sprintf(`{"type":"message","id":8,"payload":{"chat_id":5,"message_id":%d,"text":"x"}}`, sent.MessageID),
+			"Bad Request: message_id is already used"},
+		{`{"type":"message","id":3,"payload":{"chat_id":5}}`, "Bad Request: message text is empty"},
+		{`{"type":"teleport","id":4}`, "Bad Request: unknown frame type teleport"},
+		{`{"v":2,"type":"message","id":5}`, "Bad Request: unsupported protocol version"},
+		{`{not json`, ""},
+	} {
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(tc.frame)))
+		env := readEnvelope(t, conn)
+		require.Equal(t, "error", env.Type, tc.frame)
+		var p errorPayload
+		require.NoError(t, json.Unmarshal(env.Payload, &p))
+		require.Equal(t, 400, p.ErrorCode)
+		if tc.want != "" {
+			require.Equal(t, tc.want, p.Description)
+		}
+	}
+}
+
+func TestProtocolV1_BotMessagesAreEnvelopes(t *testing.T) {
+	t.Parallel()
+	bot := newTestBot(t)
+	defer bot.Close(context.Background())
+	conn, _, err := websocket.DefaultDialer.Dial(bot.URL()+"?v=1", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	waitClients(t, bot, 1)
+
+	_, err = bot.SendMessage(context.Background(), &SendMessageParams{ChatID: ChatID{ID: 3}, Text: "hi"})
+	require.NoError(t, err)
+
+	env := readEnvelope(t, conn)
+	require.Equal(t, "message", env.Type)
+	var msg Message
+	require.NoError(t, json.Unmarshal(env.Payload, &msg))
+	require.Equal(t, int64(3), msg.Chat.ID)
+	require.Equal(t, "hi", msg.Text)
+}
+
+func TestProtocolV1_InboundMedia(t *testing.T) {
+	t.Parallel()
+	bot := newTestBot(t)
+	defer bot.Close(context.Background())
+	conn := dialWS(t, bot)
+	defer conn.Close()
+
+	updates, err := bot.UpdatesViaLongPolling(context.Background(), &GetUpdatesParams{})
+	require.NoError(t, err)
+
+	send := func(payload string) envelope {
+		t.Helper()
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage,
+			[]byte(`{"type":"message","id":1,"payload":`+payload+`}`)))
+		return readEnvelope(t, conn)
+	}
+
+	// "aGVsbG8=" is base64 for "hello"
+	require.Equal(t, "ack", send(`{"chat_id":5,"text":"invoice","document":{"name":"a.txt","data":"aGVsbG8="}}`).Type)
+	doc := (<-updates).Message
+	require.Equal(t, "invoice", doc.Caption)
+	require.Empty(t, doc.Text)
+	require.Equal(t, "a.txt", doc.Document.FileName)
+	require.Equal(t, "text/plain", doc.Document.MimeType)
+	require.EqualValues(t, 5, doc.Document.FileSize)
+	f, ok := bot.files.get(doc.Document.FileID)
+	require.True(t, ok)
+	require.Equal(t, []byte("hello"), f.data)
+
+	require.Equal(t, "ack", send(`{"chat_id":5,"document":{"file_id":"`+doc.Document.FileID+`"}}`).Type)
+	require.Equal(t, doc.Document.FileUniqueID, (<-updates).Message.Document.FileUniqueID)
+
+	require.Equal(t, "ack", send(`{"chat_id":5,"voice":{"name":"v.ogg","data":"aGVsbG8=","duration":3}}`).Type)
+	voice := (<-updates).Message.Voice
+	require.Equal(t, "audio/ogg", voice.MimeType)
+	require.Equal(t, 3, voice.Duration)
+
+	require.Equal(t, "ack", send(`{"chat_id":5,"location":{"latitude":55.75,"longitude":37.62}}`).Type)
+	require.Equal(t, &Location{Latitude: 55.75, Longitude: 37.62}, (<-updates).Message.Location)
+
+	require.Equal(t, "ack", send(`{"chat_id":5,"contact":{"phone_number":"+100","first_name":"Ann","user_id":5}}`).Type)
+	require.Equal(t, "Ann", (<-updates).Message.Contact.FirstName)
+
+	for payload, want := range map[string]string{
+		`{"chat_id":5,"photo":{"data":""}}`:                               "Bad Request: photo data is empty",
+		`{"chat_id":5,"sticker":{"file_id":"nope"}}`:                      "Bad Request: wrong file identifier/HTTP URL specified",
+		`{"chat_id":5,"voice":{"file_id":"` + doc.Document.FileID + `"}}`: "Bad Request: wrong file type",
+		`{"chat_id":5,"location":{"latitude":91,"longitude":0}}`:          "Bad Request: invalid location",
+		`{"chat_id":5,"contact":{"phone_number":"+100"}}`:                 "Bad Request: contact needs phone_number and first_name",
+	} {
+		env := send(payload)
+		require.Equal(t, "error", env.Type, payload)
+		var p errorPayload
+		require.NoError(t, json.Unmarshal(env.Payload, &p))
+		require.Equal(t, want, p.Description)
+	}
+
+	// a message that fails after its upload leaves no file behind
+	files := len(bot.files.byID)
+	require.Equal(t, "error", send(`{"chat_id":5,"document":{"name":"b.txt","data":"aGVsbG8="},"reply_to_message_id":999}`).Type)
+	require.Len(t, bot.files.byID, files)
+}
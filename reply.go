@@ -0,0 +1,134 @@
+package telemock
+
+import "unicode/utf16"
+
+var errReplyNotFound = errBadRequest("message to be replied not found")
+
+// quoteEntityTypes are the entities Telegram keeps in a quote
+var quoteEntityTypes = map[string]bool{
+	"bold": true, "italic": true, "underline": true, "strikethrough": true,
+	"spoiler": true, "custom_emoji": true,
+}
+
+// attachReply resolves reply against the stored messages: a message of the
+// same chat becomes msg.ReplyToMessage, one of another chat msg.ExternalReply.
+// A quote must be part of the replied message's text or caption.
+func (b *Bot) attachReply(msg *Message, reply *ReplyParameters) error {
+	if reply == nil || reply.MessageID == 0 {
+		return nil
+	}
+	chatID := msg.Chat.ID
+	switch {
+	case reply.ChatID.Username != "":
+		return errBadRequest("chat not found")
+	case reply.ChatID.ID != 0:
+		chatID = reply.ChatID.ID
+	}
+	orig, ok := b.store.get(chatID, reply.MessageID)
+	if !ok {
+		if reply.AllowSendingWithoutReply {
+			return nil
+		}
+		return errReplyNotFound
+	}
+	quote, err := quoteOf(orig, reply)
+	if err != nil {
+		return err
+	}
+	msg.Quote = quote
+	if chatID != msg.Chat.ID {
+		msg.ExternalReply = externalReply(orig)
+		return nil
+	}
+	// вложенное сообщение не несёт своего reply_to_message, как в Telegram
+	orig.ReplyToMessage = nil
+	msg.ReplyToMessage = &orig
+	return nil
+}
+
+// quoteOf finds the quoted part of orig, searching from the quote position.
+// Positions and lengths are in UTF-16 code units, like entity offsets.
+func quoteOf(orig Message, reply *ReplyParameters) (*TextQuote, error) {
+	if reply.Quote == "" {
+		return nil, nil
+	}
+	text, entities := orig.Text, orig.Entities
+	if text == "" {
+		text, entities = orig.Caption, orig.CaptionEntities
+	}
+	haystack, needle := utf16.Encode([]rune(text)), utf16.Encode([]rune(reply.Quote))
+	pos := indexUTF16(haystack, needle, reply.QuotePosition)
+	if pos < 0 {
+		pos = indexUTF16(haystack, needle, 0)
+	}
+	if pos < 0 {
+		return nil, errBadRequest("QUOTE_TEXT_INVALID")
+	}
+	quote := &TextQuote{Text: reply.Quote, Position: pos, IsManual: true, Entities: reply.QuoteEntities}
+	if quote.Entities != nil {
+		return quote, nil
+	}
+	end := pos + len(needle)
+	for _, e := range entities {
+		from, to := max(e.Offset, pos), min(e.Offset+e.Length, end)
+		if quoteEntityTypes[e.Type] && from < to {
+			quote.Entities = append(quote.Entities, MessageEntity{Type: e.Type, Offset: from - pos, Length: to - from})
+		}
+	}
+	return quote, nil
+}
+
+// indexUTF16 returns the first index of needle in s at or after from, or -1
+func indexUTF16(s, needle []uint16, from int) int {
+	if from < 0 {
+		return -1
+	}
+	for i := from; i+len(needle) <= len(s); i++ {
+		match := true
+		for j := range needle {
+			if s[i+j] != needle[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
+
+// externalReply describes a message of another chat. Its chat and ID are
+// shown for supergroups and channels only, as in Telegram.
+func externalReply(orig Message) *ExternalReplyInfo {
+	info := &ExternalReplyInfo{
+		LinkPreviewOptions: orig.LinkPreviewOptions,
+		Animation:          orig.Animation,
+		Audio:              orig.Audio,
+		Document:           orig.Document,
+		Photo:              orig.Photo,
+		Sticker:            orig.Sticker,
+		Video:              orig.Video,
+		Voice:              orig.Voice,
+		HasMediaSpoiler:    orig.HasMediaSpoiler,
+		Contact:            orig.Contact,
+		Location:           orig.Location,
+	}
+	switch {
+	case orig.Chat.Type == ChatTypeChannel:
+		info.Origin = &MessageOriginChannel{Type: OriginTypeChannel, Date: orig.Date, Chat: orig.Chat,
+			MessageID: orig.MessageID, AuthorSignature: orig.AuthorSignature}
+	case orig.SenderChat != nil:
+		info.Origin = &MessageOriginChat{Type: OriginTypeChat, Date: orig.Date, SenderChat: *orig.SenderChat,
+			AuthorSignature: orig.AuthorSignature}
+	case orig.From != nil:
+		info.Origin = &MessageOriginUser{Type: OriginTypeUser, Date: orig.Date, SenderUser: *orig.From}
+	default:
+		info.Origin = &MessageOriginHiddenUser{Type: OriginTypeHiddenUser, Date: orig.Date}
+	}
+	if orig.Chat.Type == ChatTypeSupergroup || orig.Chat.Type == ChatTypeChannel {
+		chat := orig.Chat
+		info.Chat, info.MessageID = &chat, orig.MessageID
+	}
+	return info
+}
@@ -0,0 +1,115 @@
+package telemock
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttachReply(t *testing.T) {
+	t.Parallel()
+	bot, err := NewBot("token", WithoutListener(), WithoutLogFile(), WithLogger(nil))
+	require.NoError(t, err)
+	defer bot.Close(context.Background())
+	ctx := context.Background()
+
+	question, err := bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: 1}, Text: "Ваш любимый цвет? Выберите",
+		Entities: []MessageEntity{{Type: "bold", Offset: 5, Length: 7}, {Type: "url", Offset: 0, Length: 4}}})
+	require.NoError(t, err)
+
+	// the reply carries the stored message, the quote its position in UTF-16 units
+	upd, err := bot.textUpdate(1, 0, 0, "red")
+	require.NoError(t, err)
+	require.NoError(t, bot.attachReply(upd.Message, &ReplyParameters{MessageID: question.MessageID, Quote: "любимый цвет"}))
+	require.Equal(t, question, upd.Message.ReplyToMessage)
+	require.Equal(t, &TextQuote{Text: "любимый цвет", Position: 4, IsManual: true,
+		Entities: []MessageEntity{{Type: "bold", Offset: 1, Length: 7}}}, upd.Message.Quote)
+	require.NoError(t, bot.Inject(upd))
+	<-bot.updates
+
+	// replies to a reply don't nest further
+	answer, err := bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: 1}, Text: "noted",
+		ReplyParameters: &ReplyParameters{MessageID: upd.Message.MessageID}})
+	require.NoError(t, err)
+	require.Equal(t, "red", answer.ReplyToMessage.Text)
+	require.Nil(t, answer.ReplyToMessage.ReplyToMessage)
+
+	err = bot.attachReply(upd.Message, &ReplyParameters{MessageID: question.MessageID, Quote: "blue"})
+	require.EqualError(t, err, `telemock: api: 400 "Bad Request: QUOTE_TEXT_INVALID"`)
+	require.ErrorIs(t, bot.attachReply(upd.Message, &ReplyParameters{MessageID: 999}), errReplyNotFound)
+	require.NoError(t, bot.attachReply(upd.Message, &ReplyParameters{MessageID: 999, AllowSendingWithoutReply: true}))
+
+	// the bot can't reply to a message telemock doesn't know, unless allowed
+	_, err = bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: 1}, Text: "?",
+		ReplyParameters: &ReplyParameters{MessageID: 999}})
+	require.ErrorIs(t, err, errReplyNotFound)
+	plain, err := bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: 1}, Text: "?",
+		ReplyParameters: &ReplyParameters{MessageID: 999, AllowSendingWithoutReply: true}})
+	require.NoError(t, err)
+	require.Nil(t, plain.ReplyToMessage)
+}
+
+func TestAttachReply_External(t *testing.T) {
+	t.Parallel()
+	bot, err := NewBot("token", WithoutListener(), WithoutLogFile(), WithLogger(nil))
+	require.NoError(t, err)
+	defer bot.Close(context.Background())
+	ctx := context.Background()
+
+	channel := Chat{ID: -200, Type: ChatTypeChannel, Title: "News"}
+	require.NoError(t, bot.CreateChat(channel))
+	post, err := bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: channel.ID}, Text: "big news"})
+	require.NoError(t, err)
+
+	upd, err := bot.textUpdate(1, 0, 0, "wow")
+	require.NoError(t, err)
+	require.NoError(t, bot.attachReply(upd.Message, &ReplyParameters{ChatID: ChatID{ID: channel.ID}, MessageID: post.MessageID, Quote: "news"}))
+	require.Nil(t, upd.Message.ReplyToMessage)
+	require.Equal(t, &ExternalReplyInfo{
+		Origin:    &MessageOriginChannel{Type: OriginTypeChannel, Date: post.Date, Chat: channel, MessageID: post.MessageID},
+		Chat:      &channel,
+		MessageID: post.MessageID,
+	}, upd.Message.ExternalReply)
+	require.Equal(t, "news", upd.Message.Quote.Text)
+
+	// the origin survives a round trip through JSON
+	raw, err := json.Marshal(upd.Message)
+	require.NoError(t, err)
+	var decoded Message
+	require.NoError(t, json.Unmarshal(raw, &decoded))
+	require.Equal(t, upd.Message.ExternalReply, decoded.ExternalReply)
+}
+
+func TestReplyFrame(t *testing.T) {
+	t.Parallel()
+	bot := newTestBot(t)
+	defer bot.Close(context.Background())
+	conn, _, err := websocket.DefaultDialer.Dial(bot.URL()+"?v=1", nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	waitClients(t, bot, 1)
+	updates, err := bot.UpdatesViaLongPolling(context.Background(), &GetUpdatesParams{})
+	require.NoError(t, err)
+
+	question, err := bot.SendMessage(context.Background(), &SendMessageParams{ChatID: ChatID{ID: 3}, Text: "how are you?"})
+	require.NoError(t, err)
+	require.Equal(t, "message", readEnvelope(t, conn).Type)
+
+	send := func(payload any) envelope {
+		t.Helper()
+		frame, _ := json.Marshal(map[string]any{"v": 1, "type": "message", "id": 1, "payload": payload})
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, frame))
+		return readEnvelope(t, conn)
+	}
+	require.Equal(t, "ack", send(map[string]any{"chat_id": 3, "text": "fine", "reply_to_message_id": question.MessageID, "quote": "how"}).Type)
+	upd := <-updates
+	require.Equal(t, question, upd.Message.ReplyToMessage)
+	require.Equal(t, 0, upd.Message.Quote.Position)
+
+	env := send(map[string]any{"chat_id": 3, "text": "fine", "reply_to_message_id": 12345})
+	require.Equal(t, "error", env.Type)
+	require.Contains(t, string(env.Payload), "message to be replied not found")
+}
@@ -0,0 +1,368 @@
+package telemock
+
+import (
+	"slices"
+	"sync"
+)
+
+// chatStore keeps every message telemock has seen, per chat, so bot methods
+// can edit, delete and quote them and clients can replay the history.
+// Messages are stored by value: callers get copies and replace entries
+// instead of mutating them.
+type chatStore struct {
+	mu    sync.RWMutex
+	chats map[int64]*chatState
+	order []int64        // chat IDs in the order telemock first saw them
+	users map[int64]User // registered user profiles
+}
+
+type chatState struct {
+	chat      Chat
+	messages  []int // message IDs in arrival order
+	byID      map[int]Message
+	fromBot   map[int]bool         // messages the bot sent
+	keyboard  *ReplyKeyboardMarkup // active reply keyboard
+	members   map[int64]ChatMember // groups and channels only
+	memberIDs []int64              // in joining order
+	changes   []Change             // every message, edit and deletion
+}
+
+func newChatStore() *chatStore {
+	return &chatStore{chats: make(map[int64]*chatState), users: make(map[int64]User)}
+}
+
+// state returns the chat's state, creating it on first use; s.mu must be held
+func (s *chatStore) state(chat Chat) *chatState {
+	st, ok := s.chats[chat.ID]
+	if !ok {
+		st = &chatState{chat: chat, byID: make(map[int]Message), fromBot: make(map[int]bool)}
+		s.chats[chat.ID] = st
+		s.order = append(s.order, chat.ID)
+	}
+	return st
+}
+
+// put stores msg, replacing a message with the same ID as an edit. fromBot
+// tells the bot's own messages from those of users.
+func (s *chatStore) put(msg Message, fromBot bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st := s.state(msg.Chat)
+	change := Change{Type: EventMessage, FromBot: fromBot, Message: msg}
+	if prev, ok := st.byID[msg.MessageID]; ok {
+		change.Type, change.Previous = EventEdit, &prev
+	} else {
+		st.messages = append(st.messages, msg.MessageID)
+	}
+	st.byID[msg.MessageID] = msg
+	st.fromBot[msg.MessageID] = fromBot
+	st.changes = append(st.changes, change)
+}
+
+// changes returns the changes of a chat's messages, oldest first
+func (s *chatStore) changes(chatID int64) []Change {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	st, ok := s.chats[chatID]
+	if !ok {
+		return nil
+	}
+	return slices.Clone(st.changes)
+}
+
+// revert undoes the latest change of a message that never reached the bot:
+// an edit gives way to the previous version, a new message is dropped
+func (s *chatStore) revert(chatID int64, msgID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.chats[chatID]
+	if !ok {
+		return
+	}
+	for i := len(st.changes) - 1; i >= 0; i-- {
+		change := st.changes[i]
+		if change.Message.MessageID != msgID {
+			continue
+		}
+		if change.Type == EventMessage {
+			st.drop(msgID)
+			return
+		}
+		st.changes = slices.Delete(st.changes, i, i+1)
+		if change.Type == EventEdit {
+			st.byID[msgID] = *change.Previous
+		}
+		return
+	}
+}
+
+// drop forgets a message that never reached the bot, with all its changes
+func (s *chatStore) drop(chatID int64, msgID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if st, ok := s.chats[chatID]; ok {
+		st.drop(msgID)
+	}
+}
+
+func (st *chatState) drop(msgID int) {
+	delete(st.byID, msgID)
+	delete(st.fromBot, msgID)
+	st.messages = slices.DeleteFunc(st.messages, func(id int) bool { return id == msgID })
+	st.changes = slices.DeleteFunc(st.changes, func(c Change) bool { return c.Message.MessageID == msgID })
+}
+
+// sentByBot reports whether the bot sent a stored message
+func (s *chatStore) sentByBot(chatID int64, msgID int) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	st, ok := s.chats[chatID]
+	return ok && st.fromBot[msgID]
+}
+
+// storedMessage is a message in a chat's history
+type storedMessage struct {
+	msg     Message
+	fromBot bool
+}
+
+// history returns copies of a chat's messages, oldest first
+func (s *chatStore) history(chatID int64) []storedMessage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	st, ok := s.chats[chatID]
+	if !ok {
+		return nil
+	}
+	out := make([]storedMessage, 0, len(st.messages))
+	for _, id := range st.messages {
+		out = append(out, storedMessage{msg: st.byID[id], fromBot: st.fromBot[id]})
+	}
+	return out
+}
+
+// last returns the newest message of a chat
+func (s *chatStore) last(chatID int64) (Message, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	st, ok := s.chats[chatID]
+	if !ok || len(st.messages) == 0 {
+		return Message{}, false
+	}
+	return st.byID[st.messages[len(st.messages)-1]], true
+}
+
+// chatIDs returns every chat telemock has seen, in the order it saw them
+func (s *chatStore) chatIDs() []int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return slices.Clone(s.order)
+}
+
+// get returns a copy of a stored message
+func (s *chatStore) get(chatID int64, msgID int) (Message, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	st, ok := s.chats[chatID]
+	if !ok {
+		return Message{}, false
+	}
+	msg, ok := st.byID[msgID]
+	return msg, ok
+}
+
+// update applies the bot's edit fn to a copy of a stored message and stores
+// the result if fn succeeds. It reports false when the message does not exist.
+func (s *chatStore) update(chatID int64, msgID int, fn func(*Message) error) (Message, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.chats[chatID]
+	if !ok {
+		return Message{}, false, nil
+	}
+	msg, ok := st.byID[msgID]
+	if !ok {
+		return Message{}, false, nil
+	}
+	prev := msg
+	if err := fn(&msg); err != nil {
+		return Message{}, true, err
+	}
+	st.byID[msgID] = msg
+	st.changes = append(st.changes, Change{Type: EventEdit, FromBot: true, Message: msg, Previous: &prev})
+	return msg, true, nil
+}
+
+// remove deletes the listed messages of a chat that pass check, for the bot.
+// Unknown IDs are skipped; a message rejected by check stays and its error is
+// returned after the rest were processed. It returns the IDs actually removed.
+func (s *chatStore) remove(chatID int64, ids []int, check func(Message) error) ([]int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.chats[chatID]
+	if !ok {
+		return nil, nil
+	}
+	var removed []int
+	var firstErr error
+	for _, id := range ids {
+		msg, ok := st.byID[id]
+		if !ok {
+			continue
+		}
+		if err := check(msg); err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		delete(st.byID, id)
+		delete(st.fromBot, id)
+		st.changes = append(st.changes, Change{Type: EventDelete, FromBot: true, Message: msg})
+		removed = append(removed, id)
+	}
+	if len(removed) > 0 {
+		kept := st.messages[:0]
+		for _, id := range st.messages {
+			if _, ok := st.byID[id]; ok {
+				kept = append(kept, id)
+			}
+		}
+		st.messages = kept
+	}
+	return removed, firstErr
+}
+
+// applyMarkup updates the chat's reply keyboard after a bot message carrying
+// markup. Inline keyboards and forced replies leave it alone.
+func (s *chatStore) applyMarkup(chatID int64, markup ReplyMarkup) {
+	switch m := markup.(type) {
+	case *ReplyKeyboardMarkup:
+		s.mu.Lock()
+		s.state(Chat{ID: chatID}).keyboard = m
+		s.mu.Unlock()
+	case *ReplyKeyboardRemove:
+		s.mu.Lock()
+		s.state(Chat{ID: chatID}).keyboard = nil
+		s.mu.Unlock()
+	}
+}
+
+// useKeyboard hides a one-time keyboard once the user pressed one of its buttons
+func (s *chatStore) useKeyboard(msg *Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.chats[msg.Chat.ID]
+	if ok && st.keyboard != nil && st.keyboard.OneTimeKeyboard && st.keyboard.pressed(msg) {
+		st.keyboard = nil
+	}
+}
+
+func (s *chatStore) keyboard(chatID int64) *ReplyKeyboardMarkup {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if st, ok := s.chats[chatID]; ok {
+		return st.keyboard
+	}
+	return nil
+}
+
+// chat returns a registered group or channel
+func (s *chatStore) chat(id int64) (Chat, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if st, ok := s.chats[id]; ok && st.members != nil {
+		return st.chat, true
+	}
+	return Chat{}, false
+}
+
+// createChat registers a group or channel with its members
+func (s *chatStore) createChat(chat Chat, members []ChatMember) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.chats[chat.ID]; ok {
+		return false
+	}
+	st := s.state(chat)
+	st.members = make(map[int64]ChatMember)
+	for _, m := range members {
+		st.setMember(m)
+	}
+	return true
+}
+
+// setMember adds or replaces a member and returns the previous state
+func (st *chatState) setMember(m ChatMember) ChatMember {
+	id := m.MemberUser().ID
+	old, ok := st.members[id]
+	if !ok {
+		st.memberIDs = append(st.memberIDs, id)
+	}
+	st.members[id] = m
+	return old
+}
+
+// setMember changes a member of a registered chat. It returns the member's
+// previous state, nil if the user was never in the chat.
+func (s *chatStore) setMember(chatID int64, m ChatMember) (ChatMember, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	st, ok := s.chats[chatID]
+	if !ok || st.members == nil {
+		return nil, false
+	}
+	return st.setMember(m), true
+}
+
+func (s *chatStore) member(chatID, userID int64) (ChatMember, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	st, ok := s.chats[chatID]
+	if !ok || st.members == nil {
+		return nil, false
+	}
+	m, ok := st.members[userID]
+	return m, ok
+}
+
+// members returns everyone who was ever in a registered chat, left and
+// banned users included, in joining order
+func (s *chatStore) members(chatID int64) []ChatMember {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	st, ok := s.chats[chatID]
+	if !ok {
+		return nil
+	}
+	out := make([]ChatMember, 0, len(st.memberIDs))
+	for _, id := range st.memberIDs {
+		out = append(out, st.members[id])
+	}
+	return out
+}
+
+// user returns the registered profile of a user
+func (s *chatStore) user(id int64) (User, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	u, ok := s.users[id]
+	return u, ok
+}
+
+// setUser registers a user profile and applies rename to every membership of
+// the user. It returns the chats whose members changed.
+func (s *chatStore) setUser(u User, rename func(ChatMember) ChatMember) []int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[u.ID] = u
+	var changed []int64
+	for id, st := range s.chats {
+		if m, ok := st.members[u.ID]; ok {
+			st.members[u.ID] = rename(m)
+			changed = append(changed, id)
+		}
+	}
+	slices.Sort(changed)
+	return changed
+}
@@ -0,0 +1,77 @@
+package telemock
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// telegoBot lists the methods of telego's Bot (v1.8) that telemock implements.
+// The telegocheck module compares their signatures with telego's own; bot
+// code written against this subset builds with either package.
+type telegoBot interface {
+	UpdatesViaLongPolling(ctx context.Context, params *GetUpdatesParams, options ...LongPollingOption) (<-chan Update, error)
+	GetMe(ctx context.Context) (*User, error)
+	Close(ctx context.Context) error
+	FileDownloadURL(filepath string) string
+
+	SendMessage(ctx context.Context, params *SendMessageParams) (*Message, error)
+	SendPhoto(ctx context.Context, params *SendPhotoParams) (*Message, error)
+	SendAudio(ctx context.Context, params *SendAudioParams) (*Message, error)
+	SendDocument(ctx context.Context, params *SendDocumentParams) (*Message, error)
+	SendVideo(ctx context.Context, params *SendVideoParams) (*Message, error)
+	SendAnimation(ctx context.Context, params *SendAnimationParams) (*Message, error)
+	SendVoice(ctx context.Context, params *SendVoiceParams) (*Message, error)
+	GetFile(ctx context.Context, params *GetFileParams) (*File, error)
+	AnswerCallbackQuery(ctx context.Context, params *AnswerCallbackQueryParams) error
+	GetChatMember(ctx context.Context, params *GetChatMemberParams) (ChatMember, error)
+	GetChatAdministrators(ctx context.Context, params *GetChatAdministratorsParams) ([]ChatMember, error)
+	GetChatMemberCount(ctx context.Context, params *GetChatMemberCountParams) (*int, error)
+
+	EditMessageText(ctx context.Context, params *EditMessageTextParams) (*Message, error)
+	EditMessageCaption(ctx context.Context, params *EditMessageCaptionParams) (*Message, error)
+	EditMessageReplyMarkup(ctx context.Context, params *EditMessageReplyMarkupParams) (*Message, error)
+	DeleteMessage(ctx context.Context, params *DeleteMessageParams) error
+	DeleteMessages(ctx context.Context, params *DeleteMessagesParams) error
+}
+
+var _ telegoBot = (*Bot)(nil)
+
+// readsTelegoFields uses the type fields handlers commonly read, so that a
+// field missing from the mirror fails the build.
+func readsTelegoFields(upd Update) []any {
+	msg := upd.Message
+	cq := upd.CallbackQuery
+	return []any{
+		upd.UpdateID, msg.Date, msg.Chat.Type, msg.Chat.Title, msg.From.Username, msg.From.IsPremium,
+		msg.ReplyToMessage.MessageID, msg.Quote.Text, msg.SenderChat, msg.NewChatMembers, msg.EditDate,
+		cq.From.FirstName, cq.ChatInstance, cq.Message.GetChat().ID, cq.Message.GetDate(), cq.Message.Message(),
+	}
+}
+
+var _ = readsTelegoFields
+
+func TestCallbackQuery_MaybeInaccessibleMessage(t *testing.T) {
+	t.Parallel()
+	var upd Update
+	require.NoError(t, json.Unmarshal([]byte(`{"update_id":1,"callback_query":{"id":"a","from":{"id":5,"is_bot":false,"first_name":"Ann"},
+		"chat_instance":"5","message":{"message_id":7,"date":1700000000,"chat":{"id":5,"type":"private"},"text":"menu"}}}`), &upd))
+	require.True(t, upd.CallbackQuery.Message.IsAccessible())
+	require.Equal(t, "menu", upd.CallbackQuery.Message.Message().Text)
+
+	require.NoError(t, json.Unmarshal([]byte(`{"update_id":2,"callback_query":{"id":"b","from":{"id":5,"is_bot":false,"first_name":"Ann"},
+		"chat_instance":"5","message":{"message_id":8,"date":0,"chat":{"id":5,"type":"private"}}}}`), &upd))
+	require.False(t, upd.CallbackQuery.Message.IsAccessible())
+	require.Equal(t, 8, upd.CallbackQuery.Message.GetMessageID())
+	require.Equal(t, int64(5), upd.CallbackQuery.Message.GetChat().ID)
+
+	// кнопка под удалённым сообщением
+	bot := newTestBot(t)
+	defer bot.Close(context.Background())
+	upd, err := bot.callbackUpdate(5, 0, 99, "", "x")
+	require.NoError(t, err)
+	cb := upd.CallbackQuery
+	require.Equal(t, &InaccessibleMessage{Chat: Chat{ID: 5, Type: ChatTypePrivate}, MessageID: 99}, cb.Message)
+}
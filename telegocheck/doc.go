@@ -0,0 +1,4 @@
+// Package telegocheck checks that telemock's Bot keeps telego's method
+// signatures. It is a separate module so that telemock itself doesn't depend
+// on telego; run its tests with `cd telegocheck && go test ./...`.
+package telegocheck
@@ -0,0 +1,97 @@
+package telegocheck
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mymmrac/telego"
+
+	telemock "github.com/teterevlev/telemock-go"
+)
+
+// TestBot_MatchesTelego compares every method of telemock's Bot that telego's
+// Bot has too: parameters and results must have the same types, and each
+// field of a telemock struct must exist in telego's with the same type and
+// JSON name. Telego's structs may have more fields.
+func TestBot_MatchesTelego(t *testing.T) {
+	mock, real := reflect.TypeOf((*telemock.Bot)(nil)), reflect.TypeOf((*telego.Bot)(nil))
+	c := checker{t: t, seen: map[[2]reflect.Type]bool{}}
+	n := 0
+	for i := 0; i < mock.NumMethod(); i++ {
+		m := mock.Method(i)
+		rm, ok := real.MethodByName(m.Name)
+		if !ok {
+			continue
+		}
+		n++
+		// параметр 0 метода — получатель, *telemock.Bot против *telego.Bot
+		c.compareFunc("Bot."+m.Name, m.Type, rm.Type, 1)
+	}
+	if n < 20 {
+		t.Errorf("only %d methods found in telego's Bot", n)
+	}
+}
+
+// checker compares telemock types with telego's, once per pair
+type checker struct {
+	t    *testing.T
+	seen map[[2]reflect.Type]bool
+}
+
+func (c *checker) compare(path string, mock, real reflect.Type) {
+	key := [2]reflect.Type{mock, real}
+	if c.seen[key] {
+		return
+	}
+	c.seen[key] = true
+	if mock.Kind() != real.Kind() || mock.Name() != real.Name() {
+		c.t.Errorf("%s: %s, telego has %s", path, mock, real)
+		return
+	}
+	switch mock.Kind() {
+	case reflect.Pointer, reflect.Slice, reflect.Array:
+		c.compare(path, mock.Elem(), real.Elem())
+	case reflect.Map:
+		c.compare(path+"[key]", mock.Key(), real.Key())
+		c.compare(path, mock.Elem(), real.Elem())
+	case reflect.Func:
+		c.compareFunc(path, mock, real, 0)
+	case reflect.Struct:
+		for i := 0; i < mock.NumField(); i++ {
+			f := mock.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			rf, ok := real.FieldByName(f.Name)
+			if !ok {
+				c.t.Errorf("%s.%s: telego has no such field", mock.Name(), f.Name)
+				continue
+			}
+			// omitempty and omitzero differ by Go version only
+			if name, rname := jsonName(f), jsonName(rf); name != rname {
+				c.t.Errorf("%s.%s: json name %q, telego has %q", mock.Name(), f.Name, name, rname)
+			}
+			c.compare(mock.Name()+"."+f.Name, f.Type, rf.Type)
+		}
+	}
+}
+
+// compareFunc compares the parameters from the first one on, and the results
+func (c *checker) compareFunc(path string, mock, real reflect.Type, first int) {
+	if mock.NumIn() != real.NumIn() || mock.NumOut() != real.NumOut() || mock.IsVariadic() != real.IsVariadic() {
+		c.t.Errorf("%s: %s, telego has %s", path, mock, real)
+		return
+	}
+	for i := first; i < mock.NumIn(); i++ {
+		c.compare(path+"#in", mock.In(i), real.In(i))
+	}
+	for i := 0; i < mock.NumOut(); i++ {
+		c.compare(path+"#out", mock.Out(i), real.Out(i))
+	}
+}
+
+func jsonName(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	return name
+}
@@ -3,7 +3,10 @@ package telemock
 import (
 	"context"
 	"encoding/json"
-	"net/url"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -11,33 +14,48 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-// dialWS retries websocket dial until success or timeout
-func dialWS(t *testing.T) *websocket.Conn {
-	deadline := time.Now().Add(2 * time.Second)
-	var conn *websocket.Conn
-	var err error
-	for time.Now().Before(deadline) {
-		u := url.URL{Scheme: "ws", Host: "127.0.0.1:8765", Path: "/"}
-		conn, _, err = websocket.DefaultDialer.Dial(u.String(), nil)
-		if err == nil {
-			return conn
-		}
-		time.Sleep(25 * time.Millisecond)
-	}
+// newTestBot starts a bot on an ephemeral port without request logs
+func newTestBot(t *testing.T, opts ...BotOption) *Bot {
+	opts = append([]BotOption{WithAddr("127.0.0.1:0"), WithoutLogFile(), WithLogger(nil)}, opts...)
+	bot, err := NewBot("token", opts...)
 	require.NoError(t, err)
-	return nil
+	return bot
 }
 
-func TestSendMessage_ReplyFieldsAndDelivery(t *testing.T) {
-	bot, err := NewBot("token")
+// dialWS waits for the bot to be ready and connects to it
+func dialWS(t *testing.T, bot *Bot) *websocket.Conn {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, bot.WaitReady(ctx))
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, bot.URL(), nil)
 	require.NoError(t, err)
+	return conn
+}
 
-	conn := dialWS(t)
+// waitClients waits until the server has registered n websocket connections
+func waitClients(t *testing.T, bot *Bot, n int) {
+	t.Helper()
+	require.Eventually(t, func() bool {
+		bot.mu.RLock()
+		defer bot.mu.RUnlock()
+		return len(bot.clients) == n
+	}, 2*time.Second, 5*time.Millisecond)
+}
+
+func TestSendMessage_ReplyFieldsAndDelivery(t *testing.T) {
+	t.Parallel()
+	bot := newTestBot(t)
+
+	conn := dialWS(t, bot)
+
+	question, err := bot.textUpdate(123, 0, 42, "Hi")
+	require.NoError(t, err)
+	require.NoError(t, bot.Inject(question))
 
 	params := &SendMessageParams{
-		ChatID:           ChatID{ID: 123},
-		Text:             "Hello",
-		ReplyToMessageID: 42,
+		ChatID:          ChatID{ID: 123},
+		Text:            "Hello",
+		ReplyParameters: &ReplyParameters{MessageID: 42},
 	}
 	_, err = bot.SendMessage(context.Background(), params)
 	require.NoError(t, err)
@@ -60,10 +78,10 @@ func TestSendMessage_ReplyFieldsAndDelivery(t *testing.T) {
 }
 
 func TestUpdatesViaLongPolling_TextMessage(t *testing.T) {
-	bot, err := NewBot("token")
-	require.NoError(t, err)
+	t.Parallel()
+	bot := newTestBot(t)
 
-	conn := dialWS(t)
+	conn := dialWS(t, bot)
 
 	updates, err := bot.UpdatesViaLongPolling(context.Background(), &GetUpdatesParams{})
 	require.NoError(t, err)
@@ -89,10 +107,10 @@ func TestUpdatesViaLongPolling_TextMessage(t *testing.T) {
 }
 
 func TestUpdatesViaLongPolling_Callback(t *testing.T) {
-	bot, err := NewBot("token")
-	require.NoError(t, err)
+	t.Parallel()
+	bot := newTestBot(t)
 
-	conn := dialWS(t)
+	conn := dialWS(t, bot)
 
 	updates, err := bot.UpdatesViaLongPolling(context.Background(), &GetUpdatesParams{})
 	require.NoError(t, err)
@@ -116,3 +134,416 @@ func TestUpdatesViaLongPolling_Callback(t *testing.T) {
 	conn.Close()
 	bot.Close(context.Background())
 }
+
+func TestNewBot_OptionsAllowSideBySideBots(t *testing.T) {
+	t.Parallel()
+	first := newTestBot(t)
+	second := newTestBot(t)
+	require.NotEqual(t, first.listener.Addr().String(), second.listener.Addr().String())
+	require.Nil(t, first.logFile)
+
+	_, err := NewBot("token", WithAddr(""))
+	require.Error(t, err)
+	_, err = NewBot("token", WithUpdatesBuffer(-1))
+	require.Error(t, err)
+	_, err = NewBot("token", WithOutboxBuffer(0))
+	require.Error(t, err)
+
+	require.NoError(t, first.Close(context.Background()))
+	require.NoError(t, second.Close(context.Background()))
+}
+
+func TestBot_URLReportsEphemeralPort(t *testing.T) {
+	t.Parallel()
+	bot := newTestBot(t, WithAddr(":0"))
+	defer bot.Close(context.Background())
+
+	_, port, err := net.SplitHostPort(bot.Addr())
+	require.NoError(t, err)
+	require.NotEqual(t, "0", port)
+	require.Equal(t, "ws://127.0.0.1:"+port+"/", bot.URL())
+
+	select {
+	case <-bot.Ready():
+	case <-time.After(2 * time.Second):
+		t.Fatal("bot never became ready")
+	}
+}
+
+func TestInMemory_InjectAndOutbox(t *testing.T) {
+	t.Parallel()
+	bot, err := NewBot("token", WithoutListener(), WithoutLogFile(), WithLogger(nil))
+	require.NoError(t, err)
+	defer bot.Close(context.Background())
+	require.Empty(t, bot.Addr())
+
+	updates, err := bot.UpdatesViaLongPolling(context.Background(), &GetUpdatesParams{})
+	require.NoError(t, err)
+
+	require.NoError(t, bot.Inject(Update{Message: &Message{MessageID: 7, Chat: Chat{ID: 9}, Text: "hi"}}))
+	upd := <-updates
+	require.NotZero(t, upd.UpdateID)
+	require.Equal(t, "hi", upd.Message.Text)
+
+	_, err = bot.SendMessage(context.Background(), &SendMessageParams{
+		ChatID:          ChatID{ID: 9},
+		Text:            "hello",
+		ReplyParameters: &ReplyParameters{MessageID: 7},
+	})
+	require.NoError(t, err)
+
+	select {
+	case ev := <-bot.Outbox():
+		require.Equal(t, EventMessage, ev.Type)
+		require.Equal(t, int64(9), ev.Message.Chat.ID)
+		require.Equal(t, "hello", ev.Message.Text)
+		require.Equal(t, 7, ev.Message.ReplyToMessage.MessageID)
+	case <-time.After(time.Second):
+		t.Fatal("no event in outbox")
+	}
+}
+
+func TestSendMessage_ConcurrentSendersDoNotCorruptFrames(t *testing.T) {
+	t.Parallel()
+	bot := newTestBot(t)
+	defer bot.Close(context.Background())
+	conn := dialWS(t, bot)
+	defer conn.Close()
+
+	waitClients(t, bot, 1)
+
+	const senders, perSender = 32, 25
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < perSender; j++ {
+				_, err := bot.SendMessage(context.Background(), &SendMessageParams{
+					ChatID: ChatID{ID: int64(i + 1)},
+					Text:   "msg",
+				})
+				require.NoError(t, err)
+			}
+		}(i)
+	}
+
+	seen := make(map[float64]bool)
+	for n := 0; n < senders*perSender; n++ {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, raw, err := conn.ReadMessage()
+		require.NoError(t, err)
+		var out outboundPayload
+		require.NoError(t, json.Unmarshal(raw, &out))
+		id := out.MessageID.(float64)
+		require.False(t, seen[id], "duplicate message %v", id)
+		seen[id] = true
+	}
+	wg.Wait()
+}
+
+func TestClose_IdempotentAndRejectsLateCalls(t *testing.T) {
+	t.Parallel()
+	bot := newTestBot(t)
+	conn := dialWS(t, bot)
+	defer conn.Close()
+
+	// keep a reader busy pushing updates while the bot shuts down
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(`{"chat_id":1,"text":"spam"}`)); err != nil {
+				return
+			}
+		}
+	}()
+	defer close(stop)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	require.NoError(t, bot.Close(ctx))
+	require.NoError(t, bot.Close(ctx))
+
+	_, err := bot.SendMessage(context.Background(), &SendMessageParams{ChatID: ChatID{ID: 1}, Text: "late"})
+	require.ErrorIs(t, err, ErrBotClosed)
+	require.ErrorIs(t, bot.Inject(Update{}), ErrBotClosed)
+
+	// the updates channel is closed once shutdown completes
+	for range bot.updates {
+	}
+}
+
+func TestClose_HonorsContext(t *testing.T) {
+	t.Parallel()
+	bot := newTestBot(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := bot.Close(ctx)
+	if err != nil {
+		require.ErrorIs(t, err, context.Canceled)
+	}
+	select {
+	case <-bot.closed:
+	case <-time.After(2 * time.Second):
+		t.Fatal("server did not stop after Close")
+	}
+
+	// an open long poll doesn't hold Close up
+	bot = newTestBot(t)
+	go func() {
+		resp, err := http.Post(bot.APIURL()+"/bottoken/getUpdates", "application/json", strings.NewReader(`{"timeout":60}`))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	require.Eventually(t, func() bool {
+		if bot.pollMu.TryLock() {
+			bot.pollMu.Unlock()
+			return false
+		}
+		return true
+	}, 2*time.Second, time.Millisecond)
+	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	require.NoError(t, bot.Close(ctx))
+	require.Less(t, time.Since(start), time.Second)
+	select {
+	case _, ok := <-bot.updates:
+		require.False(t, ok)
+	default:
+		t.Fatal("updates channel still open after Close")
+	}
+}
+
+func TestClose_EndsLongPoll(t *testing.T) {
+	t.Parallel()
+	// на MockClock таймаут опроса сам не истечёт
+	clock := NewMockClock(time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC))
+	bot := newTestBot(t, WithClock(clock))
+	polled := make(chan struct{})
+	go func() {
+		defer close(polled)
+		resp, err := http.Post(bot.APIURL()+"/bottoken/getUpdates", "application/json", strings.NewReader(`{"timeout":30}`))
+		if err == nil {
+			resp.Body.Close()
+		}
+	}()
+	require.Eventually(t, func() bool {
+		clock.mu.Lock()
+		defer clock.mu.Unlock()
+		return len(clock.timers) == 1
+	}, 2*time.Second, time.Millisecond)
+
+	closed := make(chan error, 1)
+	go func() { closed <- bot.Close(context.Background()) }()
+	select {
+	case err := <-closed:
+		require.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Close hung on an open getUpdates request")
+	}
+	<-polled
+	_, ok := <-bot.updates
+	require.False(t, ok)
+}
+
+func TestUpdateOverflowPolicies(t *testing.T) {
+	t.Parallel()
+	inject := func(bot *Bot, ids ...int) (errs []error) {
+		for _, id := range ids {
+			errs = append(errs, bot.Inject(Update{UpdateID: id}))
+		}
+		return errs
+	}
+	newBot := func(policy OverflowPolicy) *Bot {
+		bot, err := NewBot("token", WithoutListener(), WithoutLogFile(), WithLogger(nil),
+			WithUpdatesBuffer(1), WithUpdateOverflow(policy))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = bot.Close(context.Background()) })
+		return bot
+	}
+
+	bot := newBot(OverflowDropOldest)
+	inject(bot, 1, 2, 3)
+	require.Equal(t, uint64(2), bot.DroppedUpdates())
+	require.Equal(t, 3, (<-bot.updates).UpdateID)
+
+	bot = newBot(OverflowDropNewest)
+	inject(bot, 1, 2, 3)
+	require.Equal(t, uint64(2), bot.DroppedUpdates())
+	require.Equal(t, 1, (<-bot.updates).UpdateID)
+
+	bot = newBot(OverflowReject)
+	errs := inject(bot, 1, 2)
+	require.NoError(t, errs[0])
+	require.ErrorIs(t, errs[1], ErrUpdatesFull)
+	require.Equal(t, uint64(1), bot.DroppedUpdates())
+
+	bot = newBot(OverflowBlock)
+	inject(bot, 1)
+	injected := make(chan struct{})
+	go func() {
+		inject(bot, 2)
+		close(injected)
+	}()
+	select {
+	case <-injected:
+		t.Fatal("inject did not block on a full buffer")
+	case <-time.After(50 * time.Millisecond):
+	}
+	require.Equal(t, 1, (<-bot.updates).UpdateID)
+	<-injected
+	require.Equal(t, 2, (<-bot.updates).UpdateID)
+	require.Zero(t, bot.DroppedUpdates())
+}
+
+func TestUpdateOverflow_ForgetsLostUpdates(t *testing.T) {
+	t.Parallel()
+	newBot := func(policy OverflowPolicy) *Bot {
+		bot, err := NewBot("token", WithoutListener(), WithoutLogFile(), WithLogger(nil),
+			WithUpdatesBuffer(1), WithUpdateOverflow(policy))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = bot.Close(context.Background()) })
+		return bot
+	}
+	message := func(bot *Bot, text string) Update {
+		upd, err := bot.textUpdate(1, 0, 0, text)
+		require.NoError(t, err)
+		return upd
+	}
+
+	// вытесненное сообщение и нажатие исчезают из хранилища
+	bot := newBot(OverflowDropOldest)
+	first := message(bot, "first")
+	require.NoError(t, bot.Inject(first))
+	press, err := bot.callbackUpdate(1, 0, first.Message.MessageID, "", "go")
+	require.NoError(t, err)
+	require.NoError(t, bot.Inject(press))
+	require.NoError(t, bot.Inject(message(bot, "third")))
+	_, ok := bot.store.get(1, first.Message.MessageID)
+	require.False(t, ok)
+	require.Empty(t, bot.callbacks.unanswered())
+	last, _ := bot.LastMessage(1)
+	require.Equal(t, "third", last.Text)
+
+	// отброшенное и отклонённое сообщения не сохраняются
+	for _, policy := range []OverflowPolicy{OverflowDropNewest, OverflowReject} {
+		bot = newBot(policy)
+		kept := message(bot, "kept")
+		require.NoError(t, bot.Inject(kept))
+		lost := message(bot, "lost")
+		_ = bot.Inject(lost)
+		edit, err := bot.editUpdate(1, 0, kept.Message.MessageID, "changed")
+		require.NoError(t, err)
+		_ = bot.Inject(edit)
+		_, ok = bot.store.get(1, lost.Message.MessageID)
+		require.False(t, ok)
+		require.Len(t, bot.Changes(1), 1)
+		last, _ = bot.LastMessage(1)
+		require.Equal(t, "kept", last.Text)
+	}
+}
+
+func TestOutbox_OwnBufferAndDroppedEvents(t *testing.T) {
+	t.Parallel()
+	// the outbox keeps events even without an updates buffer
+	bot, err := NewBot("token", WithoutListener(), WithoutLogFile(), WithLogger(nil),
+		WithUpdatesBuffer(0), WithOutboxBuffer(2))
+	require.NoError(t, err)
+	defer bot.Close(context.Background())
+	for _, text := range []string{"one", "two", "three"} {
+		_, err := bot.SendMessage(context.Background(), &SendMessageParams{ChatID: ChatID{ID: 1}, Text: text})
+		require.NoError(t, err)
+	}
+	require.Equal(t, uint64(1), bot.DroppedEvents())
+	require.Equal(t, "two", (<-bot.Outbox()).Message.Text)
+	require.Equal(t, "three", (<-bot.Outbox()).Message.Text)
+	require.Zero(t, bot.DroppedUpdates())
+}
+
+func TestUpdateOverflow_SendsErrorFrame(t *testing.T) {
+	t.Parallel()
+	for _, policy := range []OverflowPolicy{OverflowReject, OverflowDropNewest} {
+		bot := newTestBot(t, WithUpdatesBuffer(0), WithUpdateOverflow(policy))
+		defer bot.Close(context.Background())
+		conn := dialWS(t, bot)
+		defer conn.Close()
+
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"chat_id":1,"text":"lost"}`)))
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, raw, err := conn.ReadMessage()
+		require.NoError(t, err)
+		var frame errorFrame
+		require.NoError(t, json.Unmarshal(raw, &frame))
+		require.Equal(t, "error", frame.Type)
+		require.Equal(t, 429, frame.ErrorCode)
+		require.Equal(t, uint64(1), bot.DroppedUpdates())
+
+		// v1 frames get the error instead of an ack
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage,
+			[]byte(`{"v":1,"type":"message","id":7,"payload":{"chat_id":1,"document":{"name":"a.txt","data":"aGVsbG8="}}}`)))
+		env := readEnvelope(t, conn)
+		require.Equal(t, "error", env.Type)
+		require.JSONEq(t, `7`, string(env.ID))
+		require.Empty(t, bot.files.byID)
+	}
+}
+
+func TestRouting_ClientsOnlySeeSubscribedChats(t *testing.T) {
+	t.Parallel()
+	bot := newTestBot(t)
+	defer bot.Close(context.Background())
+	ctx := context.Background()
+
+	alice, _, err := websocket.DefaultDialer.Dial(bot.URL()+"?chat_id=1", nil)
+	require.NoError(t, err)
+	defer alice.Close()
+	observer, _, err := websocket.DefaultDialer.Dial(bot.URL()+"?observer=1", nil)
+	require.NoError(t, err)
+	defer observer.Close()
+	bob := dialWS(t, bot)
+	defer bob.Close()
+	// bob opts into routing with a handshake frame
+	require.NoError(t, bob.WriteMessage(websocket.TextMessage, []byte(`{"subscribe":[2]}`)))
+
+	require.Eventually(t, func() bool {
+		bot.mu.RLock()
+		defer bot.mu.RUnlock()
+		n := 0
+		for c := range bot.clients {
+			if !c.wants(1) {
+				n++
+			}
+		}
+		return len(bot.clients) == 3 && n == 1
+	}, 2*time.Second, 5*time.Millisecond)
+
+	for _, id := range []int64{1, 2} {
+		_, err := bot.SendMessage(ctx, &SendMessageParams{ChatID: ChatID{ID: id}, Text: "hi"})
+		require.NoError(t, err)
+	}
+
+	read := func(conn *websocket.Conn) float64 {
+		conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		_, raw, err := conn.ReadMessage()
+		require.NoError(t, err)
+		var out outboundPayload
+		require.NoError(t, json.Unmarshal(raw, &out))
+		return out.ChatID.(float64)
+	}
+	require.Equal(t, float64(1), read(alice))
+	require.Equal(t, float64(2), read(bob))
+	require.Equal(t, float64(1), read(observer))
+	require.Equal(t, float64(2), read(observer))
+
+	// nothing else arrives for alice
+	alice.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	_, _, err = alice.ReadMessage()
+	require.Error(t, err)
+}
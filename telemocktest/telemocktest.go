@@ -0,0 +1,611 @@
+// Package telemocktest runs a telemock Bot in-process and drives it through
+// virtual users, so bot handlers can be tested without hand-rolled websocket code.
+//
+//	h := telemocktest.New(t, handler)
+//	u := h.User(42)
+//	u.SendText("/start")
+//	u.ExpectMessage(t, telemocktest.Text("Hello"))
+package telemocktest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	telemock "github.com/teterevlev/telemock-go"
+)
+
+// DefaultTimeout is how long Expect* helpers wait for the bot by default.
+const DefaultTimeout = 2 * time.Second
+
+// Handler processes a single update, like the body of a long-polling loop.
+type Handler func(ctx context.Context, bot *telemock.Bot, update telemock.Update)
+
+// Harness owns a Bot listening on an ephemeral port and the goroutine
+// feeding its updates to the handler. Everything is torn down via t.Cleanup.
+type Harness struct {
+	Bot     *telemock.Bot
+	Timeout time.Duration
+
+	t      testing.TB
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// New starts a bot and runs handler for every update it receives.
+// Extra options are applied after the harness defaults.
+func New(t testing.TB, handler Handler, opts ...telemock.BotOption) *Harness {
+	t.Helper()
+	opts = append([]telemock.BotOption{
+		telemock.WithAddr("127.0.0.1:0"),
+		telemock.WithoutLogFile(),
+		telemock.WithLogger(nil),
+	}, opts...)
+	bot, err := telemock.NewBot("telemocktest", opts...)
+	if err != nil {
+		t.Fatalf("telemocktest: start bot: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates, err := bot.UpdatesViaLongPolling(ctx, &telemock.GetUpdatesParams{})
+	if err != nil {
+		cancel()
+		_ = bot.Close(context.Background())
+		t.Fatalf("telemocktest: get updates: %v", err)
+	}
+
+	h := &Harness{
+		Bot:     bot,
+		Timeout: DefaultTimeout,
+		t:       t,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go func() {
+		defer close(h.done)
+		for upd := range updates {
+			if handler != nil {
+				handler(ctx, bot, upd)
+			}
+		}
+	}()
+
+	t.Cleanup(h.close)
+	return h
+}
+
+// RequireAnswers makes the test fail at cleanup if the bot left any callback
+// query unanswered, which leaves a spinner on the button in Telegram.
+func (h *Harness) RequireAnswers() {
+	h.t.Helper()
+	h.t.Cleanup(func() {
+		for _, cq := range h.Bot.UnansweredCallbacks() {
+			h.t.Errorf("telemocktest: callback query %s (%q) was never answered", cq.ID, cq.Data)
+		}
+	})
+}
+
+func (h *Harness) close() {
+	h.cancel()
+	<-h.done
+	_ = h.Bot.Close(context.Background())
+}
+
+// User connects a virtual user chatting with the bot in chatID. The user's
+// connection is subscribed to that chat only.
+func (h *Harness) User(chatID int64) *User {
+	h.t.Helper()
+	return h.connect(chatID, 0)
+}
+
+// Member connects a virtual user writing to a group or channel as userID. The
+// chat must exist and the user must be in it, see telemock.Bot.CreateChat and
+// AddChatMember. Every member sees the bot's messages to the chat.
+func (h *Harness) Member(chatID, userID int64) *User {
+	h.t.Helper()
+	return h.connect(chatID, userID)
+}
+
+func (h *Harness) connect(chatID, userID int64) *User {
+	h.t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), h.Timeout)
+	defer cancel()
+	if err := h.Bot.WaitReady(ctx); err != nil {
+		h.t.Fatalf("telemocktest: bot not ready: %v", err)
+	}
+	url := fmt.Sprintf("%s?v=1&chat_id=%d", h.Bot.URL(), chatID)
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, url, nil)
+	if err != nil {
+		h.t.Fatalf("telemocktest: dial %s: %v", url, err)
+	}
+
+	u := &User{
+		ChatID:  chatID,
+		UserID:  userID,
+		h:       h,
+		conn:    conn,
+		inbox:   make(chan event, 256),
+		answers: make(chan telemock.CallbackAnswer, 64),
+		pending: make(map[int64]chan frame),
+	}
+	go u.readLoop()
+	h.t.Cleanup(func() { _ = conn.Close() })
+	return u
+}
+
+// Message is a bot message as delivered to the user's chat. Besides an
+// inline keyboard, a message may carry one of the other reply markups.
+type Message struct {
+	telemock.Message
+	ReplyKeyboard  *telemock.ReplyKeyboardMarkup
+	ForceReply     *telemock.ForceReply
+	RemoveKeyboard bool
+}
+
+// decodeMessage reads a message frame payload, telling reply markups apart
+func decodeMessage(payload json.RawMessage) (Message, error) {
+	var m Message
+	if err := json.Unmarshal(payload, &m.Message); err != nil {
+		return m, err
+	}
+	var raw struct {
+		ReplyMarkup map[string]json.RawMessage `json:"reply_markup"`
+	}
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return m, err
+	}
+	markup, _ := json.Marshal(raw.ReplyMarkup)
+	if raw.ReplyMarkup["inline_keyboard"] == nil {
+		m.Message.ReplyMarkup = nil
+	}
+	switch {
+	case raw.ReplyMarkup["keyboard"] != nil:
+		m.ReplyKeyboard = &telemock.ReplyKeyboardMarkup{}
+		return m, json.Unmarshal(markup, m.ReplyKeyboard)
+	case raw.ReplyMarkup["force_reply"] != nil:
+		m.ForceReply = &telemock.ForceReply{}
+		return m, json.Unmarshal(markup, m.ForceReply)
+	case raw.ReplyMarkup["remove_keyboard"] != nil:
+		m.RemoveKeyboard = true
+	}
+	return m, nil
+}
+
+// Button returns the callback data of the first inline button labelled text.
+func (m Message) Button(text string) (string, bool) {
+	if m.ReplyMarkup == nil {
+		return "", false
+	}
+	for _, row := range m.ReplyMarkup.InlineKeyboard {
+		for _, btn := range row {
+			if btn.Text == text {
+				return btn.CallbackData, true
+			}
+		}
+	}
+	return "", false
+}
+
+// frame is a websocket protocol v1 envelope, see PROTOCOL.md.
+type frame struct {
+	V       int             `json:"v"`
+	Type    string          `json:"type"`
+	ID      int64           `json:"id,omitempty"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// ack is the payload of the server's answer to a client frame.
+type ack struct {
+	UpdateID        int    `json:"update_id"`
+	MessageID       int    `json:"message_id"`
+	CallbackQueryID string `json:"callback_query_id"`
+	Action          string `json:"action"`
+	URL             string `json:"url"`
+	Query           string `json:"query"`
+	CopyText        string `json:"copy_text"`
+	ErrorCode       int    `json:"error_code"`
+	Description     string `json:"description"`
+}
+
+// event is a bot action in the user's chat: a new message, an edit or a
+// deletion.
+type event struct {
+	kind    string
+	msg     Message
+	deleted []int
+}
+
+// User is a simulated Telegram user with its own websocket connection.
+type User struct {
+	ChatID int64
+	UserID int64 // the sender in a group or channel; zero in private chats
+
+	h       *Harness
+	conn    *websocket.Conn
+	writeMu sync.Mutex
+	inbox   chan event
+	answers chan telemock.CallbackAnswer
+	readErr error // set before inbox is closed
+
+	pendingMu sync.Mutex
+	nextID    int64
+	pending   map[int64]chan frame
+}
+
+func (u *User) readLoop() {
+	for {
+		_, raw, err := u.conn.ReadMessage()
+		if err != nil {
+			u.readErr = err
+			close(u.inbox)
+			return
+		}
+		var f frame
+		if err := json.Unmarshal(raw, &f); err != nil {
+			continue
+		}
+		switch f.Type {
+		case "ack", "error":
+			u.pendingMu.Lock()
+			ch := u.pending[f.ID]
+			delete(u.pending, f.ID)
+			u.pendingMu.Unlock()
+			if ch != nil {
+				ch <- f
+			}
+		case "message", "edit":
+			m, err := decodeMessage(f.Payload)
+			if err != nil || m.Chat.ID != u.ChatID {
+				continue
+			}
+			u.inbox <- event{kind: f.Type, msg: m}
+		case "callback_answer":
+			var a telemock.CallbackAnswer
+			if err := json.Unmarshal(f.Payload, &a); err != nil {
+				continue
+			}
+			select {
+			case u.answers <- a:
+			default:
+			}
+		case "delete":
+			var d telemock.Deleted
+			if err := json.Unmarshal(f.Payload, &d); err != nil || d.ChatID != u.ChatID {
+				continue
+			}
+			u.inbox <- event{kind: f.Type, deleted: d.MessageIDs}
+		}
+	}
+}
+
+// send writes a frame and waits for the server's ack
+func (u *User) send(typ string, payload any) ack {
+	u.h.t.Helper()
+	data, err := json.Marshal(payload)
+	if err != nil {
+		u.h.t.Fatalf("telemocktest: marshal payload: %v", err)
+	}
+
+	ch := make(chan frame, 1)
+	u.pendingMu.Lock()
+	u.nextID++
+	id := u.nextID
+	u.pending[id] = ch
+	u.pendingMu.Unlock()
+
+	raw, _ := json.Marshal(frame{V: 1, Type: typ, ID: id, Payload: data})
+	u.writeMu.Lock()
+	err = u.conn.WriteMessage(websocket.TextMessage, raw)
+	u.writeMu.Unlock()
+	if err != nil {
+		u.h.t.Fatalf("telemocktest: send: %v", err)
+	}
+
+	select {
+	case f := <-ch:
+		var a ack
+		_ = json.Unmarshal(f.Payload, &a)
+		if f.Type == "error" {
+			u.h.t.Fatalf("telemocktest: %s rejected: %d %s", typ, a.ErrorCode, a.Description)
+		}
+		return a
+	case <-time.After(u.h.Timeout):
+		u.h.t.Fatalf("telemocktest: no ack for %s within %s", typ, u.h.Timeout)
+		return ack{}
+	}
+}
+
+// payload adds the chat and the sender to a frame payload
+func (u *User) payload(fields map[string]any) map[string]any {
+	fields["chat_id"] = u.ChatID
+	if u.UserID != 0 {
+		fields["from_id"] = u.UserID
+	}
+	return fields
+}
+
+// SetProfile registers the user's profile, which the bot then sees in the From
+// of everything the user sends. The ID defaults to the user's own.
+func (u *User) SetProfile(profile telemock.User) {
+	u.h.t.Helper()
+	if profile.ID == 0 {
+		profile.ID = u.UserID
+	}
+	if profile.ID == 0 {
+		profile.ID = u.ChatID
+	}
+	u.send("user", profile)
+}
+
+// SendText sends a text message from the user and returns its message ID.
+// A leading /command gets a bot_command entity.
+func (u *User) SendText(text string) int {
+	u.h.t.Helper()
+	return u.send("message", u.payload(map[string]any{"text": text})).MessageID
+}
+
+// Reply sends a text message replying to message msgID and returns its
+// message ID. The bot receives the replied message in ReplyToMessage.
+func (u *User) Reply(msgID int, text string) int {
+	u.h.t.Helper()
+	return u.send("message", u.payload(map[string]any{"text": text, "reply_to_message_id": msgID})).MessageID
+}
+
+// ReplyQuote replies to message msgID quoting part of its text.
+func (u *User) ReplyQuote(msgID int, quote, text string) int {
+	u.h.t.Helper()
+	return u.send("message", u.payload(map[string]any{"text": text, "reply_to_message_id": msgID, "quote": quote})).MessageID
+}
+
+// SendPhoto uploads an image from the user with an optional caption and
+// returns the message ID.
+func (u *User) SendPhoto(data []byte, caption string) int {
+	u.h.t.Helper()
+	return u.sendMedia("photo", map[string]any{"data": data}, caption)
+}
+
+// SendDocument uploads a file named name from the user.
+func (u *User) SendDocument(name string, data []byte, caption string) int {
+	u.h.t.Helper()
+	return u.sendMedia("document", map[string]any{"name": name, "data": data}, caption)
+}
+
+// SendVoice sends an OGG voice note of the given duration in seconds.
+func (u *User) SendVoice(data []byte, duration int) int {
+	u.h.t.Helper()
+	return u.sendMedia("voice", map[string]any{"data": data, "duration": duration}, "")
+}
+
+// SendSticker sends a sticker image with its emoji.
+func (u *User) SendSticker(data []byte, emoji string) int {
+	u.h.t.Helper()
+	return u.sendMedia("sticker", map[string]any{"data": data, "emoji": emoji}, "")
+}
+
+// SendLocation shares a point on the map.
+func (u *User) SendLocation(latitude, longitude float64) int {
+	u.h.t.Helper()
+	loc := telemock.Location{Latitude: latitude, Longitude: longitude}
+	return u.send("message", u.payload(map[string]any{"location": loc})).MessageID
+}
+
+// SendContact shares a phone contact.
+func (u *User) SendContact(phone, firstName string) int {
+	u.h.t.Helper()
+	contact := telemock.Contact{PhoneNumber: phone, FirstName: firstName}
+	return u.send("message", u.payload(map[string]any{"contact": contact})).MessageID
+}
+
+func (u *User) sendMedia(kind string, file map[string]any, caption string) int {
+	u.h.t.Helper()
+	return u.send("message", u.payload(map[string]any{kind: file, "caption": caption})).MessageID
+}
+
+// PressReplyButton presses a button of the chat's reply keyboard, which sends
+// its text, and returns the message ID.
+func (u *User) PressReplyButton(text string) int {
+	u.h.t.Helper()
+	kb := u.h.Bot.ReplyKeyboard(u.ChatID)
+	if kb == nil {
+		u.h.t.Fatalf("telemocktest: chat %d: no reply keyboard", u.ChatID)
+	}
+	for _, row := range kb.Keyboard {
+		for _, btn := range row {
+			if btn.Text == text {
+				return u.SendText(text)
+			}
+		}
+	}
+	u.h.t.Fatalf("telemocktest: chat %d: no reply button %q", u.ChatID, text)
+	return 0
+}
+
+// PressButton presses the inline button carrying data under bot message msgID
+// and returns the callback query ID the bot receives.
+func (u *User) PressButton(msgID int, data string) string {
+	u.h.t.Helper()
+	return u.send("callback", u.payload(map[string]any{"message_id": msgID, "data": data})).CallbackQueryID
+}
+
+// Pressed is what pressing an inline button did. Action is one of telemock's
+// Press* constants; callback and game buttons also have a CallbackQueryID.
+type Pressed struct {
+	Action          string
+	URL             string // opened by url, web_app and login_url buttons
+	Query           string // put into the input field by switch_inline_query buttons
+	CopyText        string
+	CallbackQueryID string
+}
+
+// Press presses the inline button labelled text under bot message msgID,
+// whatever its kind.
+func (u *User) Press(msgID int, text string) Pressed {
+	u.h.t.Helper()
+	a := u.send("press", u.payload(map[string]any{"message_id": msgID, "text": text}))
+	return Pressed{Action: a.Action, URL: a.URL, Query: a.Query, CopyText: a.CopyText, CallbackQueryID: a.CallbackQueryID}
+}
+
+// ExpectMessage waits for the next bot event in the user's chat, requires it
+// to be a new message and checks it against every matcher. The message is
+// returned for further inspection.
+func (u *User) ExpectMessage(t testing.TB, matchers ...Matcher) Message {
+	t.Helper()
+	return u.expect(t, "message", matchers)
+}
+
+// ExpectEdit waits for the next bot event in the user's chat and requires it to
+// be an edit. The returned message carries the edited state.
+func (u *User) ExpectEdit(t testing.TB, matchers ...Matcher) Message {
+	t.Helper()
+	return u.expect(t, "edit", matchers)
+}
+
+// ExpectDeleted waits for the next bot event in the user's chat, requires it to
+// be a deletion and, when ids are given, that exactly those messages were
+// deleted. It returns the deleted message IDs.
+func (u *User) ExpectDeleted(t testing.TB, ids ...int) []int {
+	t.Helper()
+	ev := u.next(t, "delete")
+	if len(ids) > 0 && !slices.Equal(ev.deleted, ids) {
+		t.Fatalf("telemocktest: chat %d: deleted messages %v, want %v", u.ChatID, ev.deleted, ids)
+	}
+	return ev.deleted
+}
+
+func (u *User) expect(t testing.TB, kind string, matchers []Matcher) Message {
+	t.Helper()
+	ev := u.next(t, kind)
+	for _, match := range matchers {
+		if err := match(ev.msg); err != nil {
+			t.Fatalf("telemocktest: chat %d: %s %d: %v", u.ChatID, kind, ev.msg.MessageID, err)
+		}
+	}
+	return ev.msg
+}
+
+// next waits for the next bot event in the user's chat and requires it to be
+// of the given kind
+func (u *User) next(t testing.TB, kind string) event {
+	t.Helper()
+	select {
+	case ev, ok := <-u.inbox:
+		if !ok {
+			t.Fatalf("telemocktest: chat %d: connection closed: %v", u.ChatID, u.readErr)
+		}
+		if ev.kind != kind {
+			if ev.kind == "delete" {
+				t.Fatalf("telemocktest: chat %d: got delete of messages %v, want %s", u.ChatID, ev.deleted, kind)
+			}
+			t.Fatalf("telemocktest: chat %d: got %s of message %d, want %s", u.ChatID, ev.kind, ev.msg.MessageID, kind)
+		}
+		return ev
+	case <-time.After(u.h.Timeout):
+		t.Fatalf("telemocktest: chat %d: no bot %s within %s", u.ChatID, kind, u.h.Timeout)
+		return event{}
+	}
+}
+
+// ExpectAnswer waits for the bot's answer to a callback query of this user,
+// such as a button pressed with PressButton, and returns it. Answers are
+// collected apart from messages, so they don't disturb ExpectMessage.
+func (u *User) ExpectAnswer(t testing.TB) telemock.CallbackAnswer {
+	t.Helper()
+	select {
+	case a := <-u.answers:
+		return a
+	case <-time.After(u.h.Timeout):
+		t.Fatalf("telemocktest: chat %d: no callback answer within %s", u.ChatID, u.h.Timeout)
+		return telemock.CallbackAnswer{}
+	}
+}
+
+// ExpectNoMessage fails if the bot writes to the user's chat within d.
+func (u *User) ExpectNoMessage(t testing.TB, d time.Duration) {
+	t.Helper()
+	select {
+	case ev, ok := <-u.inbox:
+		if ok {
+			t.Fatalf("telemocktest: chat %d: unexpected bot %s %d: %q", u.ChatID, ev.kind, ev.msg.MessageID, ev.msg.Text)
+		}
+	case <-time.After(d):
+	}
+}
+
+// Matcher checks a received message and describes the mismatch.
+type Matcher func(m Message) error
+
+// Text matches the exact message text.
+func Text(want string) Matcher {
+	return func(m Message) error {
+		if m.Text != want {
+			return fmt.Errorf("text = %q, want %q", m.Text, want)
+		}
+		return nil
+	}
+}
+
+// Caption matches the exact caption of a media message.
+func Caption(want string) Matcher {
+	return func(m Message) error {
+		if m.Caption != want {
+			return fmt.Errorf("caption = %q, want %q", m.Caption, want)
+		}
+		return nil
+	}
+}
+
+// HasReplyButton matches messages showing a reply keyboard button labelled text.
+func HasReplyButton(text string) Matcher {
+	return func(m Message) error {
+		if m.ReplyKeyboard != nil {
+			for _, row := range m.ReplyKeyboard.Keyboard {
+				for _, btn := range row {
+					if btn.Text == text {
+						return nil
+					}
+				}
+			}
+		}
+		return fmt.Errorf("no reply button %q", text)
+	}
+}
+
+// TextContains matches messages whose text contains substr.
+func TextContains(substr string) Matcher {
+	return func(m Message) error {
+		if !strings.Contains(m.Text, substr) {
+			return fmt.Errorf("text = %q, want it to contain %q", m.Text, substr)
+		}
+		return nil
+	}
+}
+
+// ReplyTo matches messages replying to message msgID.
+func ReplyTo(msgID int) Matcher {
+	return func(m Message) error {
+		var got int
+		if m.ReplyToMessage != nil {
+			got = m.ReplyToMessage.MessageID
+		}
+		if got != msgID {
+			return fmt.Errorf("reply_to_message_id = %d, want %d", got, msgID)
+		}
+		return nil
+	}
+}
+
+// HasButton matches messages with an inline button labelled text.
+func HasButton(text string) Matcher {
+	return func(m Message) error {
+		if _, ok := m.Button(text); !ok {
+			return fmt.Errorf("no inline button %q", text)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,244 @@
+package telemocktest_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	telemock "github.com/teterevlev/telemock-go"
+	"github.com/teterevlev/telemock-go/telemocktest"
+)
+
+func echoHandler(ctx context.Context, bot *telemock.Bot, upd telemock.Update) {
+	switch {
+	case upd.Message != nil && upd.Message.Text == "/menu":
+		_, _ = bot.SendMessage(ctx, &telemock.SendMessageParams{
+			ChatID: telemock.ChatID{ID: upd.Message.Chat.ID},
+			Text:   "pick one",
+			ReplyMarkup: &telemock.InlineKeyboardMarkup{InlineKeyboard: [][]telemock.InlineKeyboardButton{
+				{{Text: "Yes", CallbackData: "yes"}, {Text: "No", CallbackData: "no"}},
+				{{Text: "Docs", URL: "https://example.com/docs"}},
+			}},
+		})
+	case upd.Message != nil && upd.Message.Document != nil:
+		// download the upload the way a real bot does
+		var data []byte
+		if file, err := bot.GetFile(ctx, &telemock.GetFileParams{FileID: upd.Message.Document.FileID}); err == nil {
+			if resp, err := http.Get(bot.FileDownloadURL(file.FilePath)); err == nil {
+				data, _ = io.ReadAll(resp.Body)
+				resp.Body.Close()
+			}
+		}
+		_, _ = bot.SendMessage(ctx, &telemock.SendMessageParams{
+			ChatID: telemock.ChatID{ID: upd.Message.Chat.ID},
+			Text:   fmt.Sprintf("got %s (%d bytes): %s", upd.Message.Document.FileName, len(data), upd.Message.Caption),
+		})
+	case upd.Message != nil && upd.Message.Location != nil:
+		_, _ = bot.SendMessage(ctx, &telemock.SendMessageParams{
+			ChatID: telemock.ChatID{ID: upd.Message.Chat.ID},
+			Text:   fmt.Sprintf("you are at %.2f,%.2f", upd.Message.Location.Latitude, upd.Message.Location.Longitude),
+		})
+	case upd.Message != nil && upd.Message.Text == "/ask":
+		_, _ = bot.SendMessage(ctx, &telemock.SendMessageParams{
+			ChatID: telemock.ChatID{ID: upd.Message.Chat.ID},
+			Text:   "favourite colour?",
+			ReplyMarkup: &telemock.ReplyKeyboardMarkup{
+				Keyboard:        [][]telemock.KeyboardButton{{{Text: "Red"}, {Text: "Blue"}}},
+				OneTimeKeyboard: true,
+			},
+		})
+	case upd.Message != nil && upd.Message.Text == "/forget":
+		_ = bot.DeleteMessage(ctx, &telemock.DeleteMessageParams{
+			ChatID:    telemock.ChatID{ID: upd.Message.Chat.ID},
+			MessageID: upd.Message.MessageID,
+		})
+	case upd.Message != nil:
+		_, _ = bot.SendMessage(ctx, &telemock.SendMessageParams{
+			ChatID:          telemock.ChatID{ID: upd.Message.Chat.ID},
+			Text:            "echo: " + upd.Message.Text,
+			ReplyParameters: &telemock.ReplyParameters{MessageID: upd.Message.MessageID},
+		})
+	case upd.CallbackQuery != nil && upd.CallbackQuery.Data == "no":
+		_ = bot.AnswerCallbackQuery(ctx, &telemock.AnswerCallbackQueryParams{
+			CallbackQueryID: upd.CallbackQuery.ID,
+			Text:            "are you sure?",
+			ShowAlert:       true,
+		})
+		_, _ = bot.EditMessageText(ctx, &telemock.EditMessageTextParams{
+			ChatID:    telemock.ChatID{ID: upd.CallbackQuery.Message.GetChat().ID},
+			MessageID: upd.CallbackQuery.Message.GetMessageID(),
+			Text:      "maybe later",
+		})
+	case upd.CallbackQuery != nil:
+		_ = bot.AnswerCallbackQuery(ctx, &telemock.AnswerCallbackQueryParams{CallbackQueryID: upd.CallbackQuery.ID})
+		_, _ = bot.SendMessage(ctx, &telemock.SendMessageParams{
+			ChatID: telemock.ChatID{ID: upd.CallbackQuery.From.ID},
+			Text:   "pressed " + upd.CallbackQuery.Data,
+		})
+	}
+}
+
+func TestUser_TextAndButtons(t *testing.T) {
+	t.Parallel()
+	h := telemocktest.New(t, echoHandler)
+	u := h.User(100)
+
+	id := u.SendText("hi")
+	u.ExpectMessage(t, telemocktest.Text("echo: hi"), telemocktest.ReplyTo(id))
+
+	u.SendText("/menu")
+	menu := u.ExpectMessage(t, telemocktest.Text("pick one"), telemocktest.HasButton("Yes"))
+	data, _ := menu.Button("Yes")
+	require.NotEmpty(t, u.PressButton(menu.MessageID, data))
+	u.ExpectMessage(t, telemocktest.Text("pressed yes"))
+
+	pressed := u.Press(menu.MessageID, "Docs")
+	require.Equal(t, telemocktest.Pressed{Action: telemock.PressOpenURL, URL: "https://example.com/docs"}, pressed)
+	u.ExpectNoMessage(t, 50*time.Millisecond)
+
+	u.PressButton(menu.MessageID, "no")
+	edited := u.ExpectEdit(t, telemocktest.Text("maybe later"))
+	require.Equal(t, menu.MessageID, edited.MessageID)
+	require.Nil(t, edited.ReplyMarkup)
+}
+
+func TestUser_CallbackAnswers(t *testing.T) {
+	t.Parallel()
+	h := telemocktest.New(t, echoHandler)
+	h.RequireAnswers()
+	u := h.User(105)
+
+	u.SendText("/menu")
+	menu := u.ExpectMessage(t, telemocktest.HasButton("No"))
+	id := u.PressButton(menu.MessageID, "no")
+	u.ExpectEdit(t, telemocktest.Text("maybe later"))
+	answer := u.ExpectAnswer(t)
+	require.Equal(t, telemock.CallbackAnswer{CallbackQueryID: id, ChatID: 105, MessageID: menu.MessageID,
+		Text: "are you sure?", ShowAlert: true}, answer)
+	require.Empty(t, h.Bot.UnansweredCallbacks())
+}
+
+func TestUser_OnlySeesOwnChat(t *testing.T) {
+	t.Parallel()
+	h := telemocktest.New(t, echoHandler)
+	alice := h.User(1)
+	bob := h.User(2)
+
+	alice.SendText("from alice")
+	alice.ExpectMessage(t, telemocktest.TextContains("alice"))
+	bob.ExpectNoMessage(t, 100*time.Millisecond)
+}
+
+func TestUser_ExpectDeleted(t *testing.T) {
+	t.Parallel()
+	h := telemocktest.New(t, echoHandler)
+	u := h.User(3)
+
+	id := u.SendText("/forget")
+	u.ExpectDeleted(t, id)
+}
+
+func TestUser_Uploads(t *testing.T) {
+	t.Parallel()
+	h := telemocktest.New(t, echoHandler)
+	u := h.User(4)
+
+	u.SendDocument("report.csv", []byte("a,b\n1,2\n"), "monthly")
+	u.ExpectMessage(t, telemocktest.Text("got report.csv (8 bytes): monthly"))
+
+	u.SendLocation(55.75, 37.62)
+	u.ExpectMessage(t, telemocktest.Text("you are at 55.75,37.62"))
+}
+
+func TestUser_ReplyKeyboard(t *testing.T) {
+	t.Parallel()
+	h := telemocktest.New(t, echoHandler)
+	u := h.User(5)
+
+	u.SendText("/ask")
+	u.ExpectMessage(t, telemocktest.HasReplyButton("Blue"))
+	u.PressReplyButton("Red")
+	u.ExpectMessage(t, telemocktest.Text("echo: Red"))
+	require.Nil(t, h.Bot.ReplyKeyboard(5), "one-time keyboard is hidden after use")
+}
+
+// adminHandler lets only group administrators use /lock
+func adminHandler(ctx context.Context, bot *telemock.Bot, upd telemock.Update) {
+	msg := upd.Message
+	if msg == nil || msg.Text != "/lock" {
+		return
+	}
+	reply := "locked by " + msg.From.FirstName
+	member, err := bot.GetChatMember(ctx, &telemock.GetChatMemberParams{ChatID: telemock.ChatID{ID: msg.Chat.ID}, UserID: msg.From.ID})
+	if err != nil || (member.MemberStatus() != telemock.MemberStatusCreator && member.MemberStatus() != telemock.MemberStatusAdministrator) {
+		reply = "admins only"
+	}
+	bot.SendMessage(ctx, &telemock.SendMessageParams{ChatID: telemock.ChatID{ID: msg.Chat.ID}, Text: reply})
+}
+
+func TestMember_GroupAdmins(t *testing.T) {
+	h := telemocktest.New(t, adminHandler)
+	group := telemock.Chat{ID: -1001, Type: telemock.ChatTypeSupergroup, Title: "Team"}
+	require.NoError(t, h.Bot.CreateChat(group,
+		&telemock.ChatMemberAdministrator{User: telemock.User{ID: 1, FirstName: "Alice"}},
+		&telemock.ChatMemberMember{User: telemock.User{ID: 2, FirstName: "Bob"}}))
+	alice, bob := h.Member(group.ID, 1), h.Member(group.ID, 2)
+
+	bob.SendText("/lock")
+	bob.ExpectMessage(t, telemocktest.Text("admins only"))
+	alice.ExpectMessage(t, telemocktest.Text("admins only"))
+
+	alice.SendText("/lock")
+	alice.ExpectMessage(t, telemocktest.Text("locked by Alice"))
+}
+
+func greetHandler(ctx context.Context, bot *telemock.Bot, upd telemock.Update) {
+	if upd.Message == nil {
+		return
+	}
+	greeting := "Hello"
+	if upd.Message.From.LanguageCode == "de" {
+		greeting = "Hallo"
+	}
+	_, _ = bot.SendMessage(ctx, &telemock.SendMessageParams{
+		ChatID: telemock.ChatID{ID: upd.Message.Chat.ID},
+		Text:   fmt.Sprintf("%s, %s (@%s)", greeting, upd.Message.From.FirstName, upd.Message.From.Username),
+	})
+}
+
+func TestUser_Profile(t *testing.T) {
+	h := telemocktest.New(t, greetHandler)
+	u := h.User(42)
+	u.SetProfile(telemock.User{FirstName: "Jonas", Username: "jonas", LanguageCode: "de"})
+
+	u.SendText("/start")
+	u.ExpectMessage(t, telemocktest.Text("Hallo, Jonas (@jonas)"))
+}
+
+func TestUser_Reply(t *testing.T) {
+	h := telemocktest.New(t, func(ctx context.Context, bot *telemock.Bot, upd telemock.Update) {
+		if upd.Message == nil {
+			return
+		}
+		text := "ask me something"
+		if r := upd.Message.ReplyToMessage; r != nil {
+			text = fmt.Sprintf("%q answered with %q", r.Text, upd.Message.Text)
+		}
+		if q := upd.Message.Quote; q != nil {
+			text += " about " + q.Text
+		}
+		_, _ = bot.SendMessage(ctx, &telemock.SendMessageParams{ChatID: telemock.ChatID{ID: upd.Message.Chat.ID}, Text: text})
+	})
+	u := h.User(8)
+	u.SendText("hi")
+	question := u.ExpectMessage(t)
+
+	u.Reply(question.MessageID, "ok")
+	u.ExpectMessage(t, telemocktest.Text(`"ask me something" answered with "ok"`))
+	u.ReplyQuote(question.MessageID, "something", "what?")
+	u.ExpectMessage(t, telemocktest.Text(`"ask me something" answered with "what?" about something`))
+}
@@ -1,48 +1,484 @@
 package telemock
 
-type GetUpdatesParams struct{}
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+)
+
+type GetUpdatesParams struct {
+	Offset         int      `json:"offset,omitempty"`
+	Limit          int      `json:"limit,omitempty"`
+	Timeout        int      `json:"timeout,omitempty"`
+	AllowedUpdates []string `json:"allowed_updates,omitempty"`
+}
 
 type InlineKeyboardMarkup struct {
 	InlineKeyboard [][]InlineKeyboardButton `json:"inline_keyboard"`
 }
 
+// InlineKeyboardButton must use exactly one of the optional fields after Text.
 type InlineKeyboardButton struct {
-	Text         string `json:"text"`
-	CallbackData string `json:"callback_data"`
+	Text                         string                       `json:"text"`
+	URL                          string                       `json:"url,omitempty"`
+	CallbackData                 string                       `json:"callback_data,omitempty"`
+	WebApp                       *WebAppInfo                  `json:"web_app,omitempty"`
+	LoginURL                     *LoginURL                    `json:"login_url,omitempty"`
+	SwitchInlineQuery            *string                      `json:"switch_inline_query,omitempty"`
+	SwitchInlineQueryCurrentChat *string                      `json:"switch_inline_query_current_chat,omitempty"`
+	SwitchInlineQueryChosenChat  *SwitchInlineQueryChosenChat `json:"switch_inline_query_chosen_chat,omitempty"`
+	CopyText                     *CopyTextButton              `json:"copy_text,omitempty"`
+	CallbackGame                 *CallbackGame                `json:"callback_game,omitempty"`
+	Pay                          bool                         `json:"pay,omitempty"`
+}
+
+type WebAppInfo struct {
+	URL string `json:"url"`
 }
 
+type LoginURL struct {
+	URL                string `json:"url"`
+	ForwardText        string `json:"forward_text,omitempty"`
+	BotUsername        string `json:"bot_username,omitempty"`
+	RequestWriteAccess bool   `json:"request_write_access,omitempty"`
+}
+
+type SwitchInlineQueryChosenChat struct {
+	Query             string `json:"query,omitempty"`
+	AllowUserChats    bool   `json:"allow_user_chats,omitempty"`
+	AllowBotChats     bool   `json:"allow_bot_chats,omitempty"`
+	AllowGroupChats   bool   `json:"allow_group_chats,omitempty"`
+	AllowChannelChats bool   `json:"allow_channel_chats,omitempty"`
+}
+
+type CopyTextButton struct {
+	Text string `json:"text"`
+}
+
+// CallbackGame is a placeholder, it holds no information.
+type CallbackGame struct{}
+
+// ChatID is encoded as a number, or as an @username string when ID is zero.
 type ChatID struct {
-	ID int64 `json:"id"`
+	ID       int64
+	Username string
+}
+
+func (c ChatID) MarshalJSON() ([]byte, error) {
+	if c.ID != 0 {
+		return json.Marshal(c.ID)
+	}
+	if c.Username != "" {
+		return json.Marshal(c.Username)
+	}
+	return []byte(`""`), nil
+}
+
+func (c *ChatID) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &c.ID); err == nil {
+		return nil
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if id, err := strconv.ParseInt(s, 10, 64); err == nil {
+		c.ID = id
+		return nil
+	}
+	c.Username = s
+	return nil
+}
+
+type AnswerCallbackQueryParams struct {
+	CallbackQueryID string `json:"callback_query_id"`
+	Text            string `json:"text,omitempty"`
+	ShowAlert       bool   `json:"show_alert,omitempty"`
+	URL             string `json:"url,omitempty"`
+	CacheTime       int    `json:"cache_time,omitempty"`
 }
 
 type SendMessageParams struct {
-	ChatID           ChatID                `json:"chat_id"`
-	Text             string                `json:"text"`
-	ReplyMarkup      *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
-	ReplyToMessageID int64                 `json:"reply_to_message_id,omitempty"`
+	BusinessConnectionID    string                   `json:"business_connection_id,omitempty"`
+	ChatID                  ChatID                   `json:"chat_id"`
+	MessageThreadID         int                      `json:"message_thread_id,omitempty"`
+	DirectMessagesTopicID   int64                    `json:"direct_messages_topic_id,omitempty"`
+	Text                    string                   `json:"text"`
+	ParseMode               string                   `json:"parse_mode,omitempty"`
+	Entities                []MessageEntity          `json:"entities,omitempty"`
+	LinkPreviewOptions      *LinkPreviewOptions      `json:"link_preview_options,omitempty"`
+	DisableNotification     bool                     `json:"disable_notification,omitempty"`
+	ProtectContent          bool                     `json:"protect_content,omitempty"`
+	AllowPaidBroadcast      bool                     `json:"allow_paid_broadcast,omitempty"`
+	MessageEffectID         string                   `json:"message_effect_id,omitempty"`
+	SuggestedPostParameters *SuggestedPostParameters `json:"suggested_post_parameters,omitempty"`
+	ReplyParameters         *ReplyParameters         `json:"reply_parameters,omitempty"`
+	ReplyMarkup             ReplyMarkup              `json:"reply_markup,omitempty"`
+}
+
+// ReplyParameters describes the message being replied to, in the same chat
+// or, as an external reply, in another one.
+type ReplyParameters struct {
+	MessageID                int             `json:"message_id"`
+	ChatID                   ChatID          `json:"chat_id,omitempty"`
+	AllowSendingWithoutReply bool            `json:"allow_sending_without_reply,omitempty"`
+	Quote                    string          `json:"quote,omitempty"`
+	QuoteParseMode           string          `json:"quote_parse_mode,omitempty"`
+	QuoteEntities            []MessageEntity `json:"quote_entities,omitempty"`
+	QuotePosition            int             `json:"quote_position,omitempty"`
+	ChecklistTaskID          int             `json:"checklist_task_id,omitempty"`
+	PollOptionID             string          `json:"poll_option_id,omitempty"`
+}
+
+type LinkPreviewOptions struct {
+	IsDisabled       bool   `json:"is_disabled,omitempty"`
+	URL              string `json:"url,omitempty"`
+	PreferSmallMedia bool   `json:"prefer_small_media,omitempty"`
+	PreferLargeMedia bool   `json:"prefer_large_media,omitempty"`
+	ShowAboveText    bool   `json:"show_above_text,omitempty"`
+}
+
+type SuggestedPostParameters struct {
+	Price    *SuggestedPostPrice `json:"price,omitempty"`
+	SendDate int64               `json:"send_date,omitempty"`
+}
+
+type SuggestedPostPrice struct {
+	Currency string `json:"currency"`
+	Amount   int    `json:"amount"`
 }
 
 type Update struct {
-	UpdateID      int64          `json:"update_id"`
-	Message       *Message       `json:"message,omitempty"`
-	CallbackQuery *CallbackQuery `json:"callback_query,omitempty"`
+	UpdateID          int            `json:"update_id"`
+	Message           *Message       `json:"message,omitempty"`
+	EditedMessage     *Message       `json:"edited_message,omitempty"`
+	ChannelPost       *Message       `json:"channel_post,omitempty"`
+	EditedChannelPost *Message       `json:"edited_channel_post,omitempty"`
+	CallbackQuery     *CallbackQuery `json:"callback_query,omitempty"`
 }
 
+// Message mirrors telego's Message. Fields for features telemock doesn't
+// simulate (payments, games, stories and so on) are left out.
 type Message struct {
-	MessageID int64           `json:"message_id"`
-	From      *User           `json:"from,omitempty"`
-	Chat      Chat            `json:"chat"`
-	Text      string          `json:"text"`
-	Entities  []MessageEntity `json:"entities,omitempty"`
+	MessageID             int                   `json:"message_id"`
+	MessageThreadID       int                   `json:"message_thread_id,omitempty"`
+	From                  *User                 `json:"from,omitempty"`
+	SenderChat            *Chat                 `json:"sender_chat,omitempty"`
+	Date                  int64                 `json:"date"`
+	Chat                  Chat                  `json:"chat"`
+	IsTopicMessage        bool                  `json:"is_topic_message,omitempty"`
+	IsAutomaticForward    bool                  `json:"is_automatic_forward,omitempty"`
+	ReplyToMessage        *Message              `json:"reply_to_message,omitempty"`
+	ExternalReply         *ExternalReplyInfo    `json:"external_reply,omitempty"`
+	Quote                 *TextQuote            `json:"quote,omitempty"`
+	ViaBot                *User                 `json:"via_bot,omitempty"`
+	EditDate              int64                 `json:"edit_date,omitempty"`
+	HasProtectedContent   bool                  `json:"has_protected_content,omitempty"`
+	MediaGroupID          string                `json:"media_group_id,omitempty"`
+	AuthorSignature       string                `json:"author_signature,omitempty"`
+	Text                  string                `json:"text,omitempty"`
+	Entities              []MessageEntity       `json:"entities,omitempty"`
+	LinkPreviewOptions    *LinkPreviewOptions   `json:"link_preview_options,omitempty"`
+	EffectID              string                `json:"effect_id,omitempty"`
+	Animation             *Animation            `json:"animation,omitempty"`
+	Audio                 *Audio                `json:"audio,omitempty"`
+	Document              *Document             `json:"document,omitempty"`
+	Photo                 []PhotoSize           `json:"photo,omitempty"`
+	Sticker               *Sticker              `json:"sticker,omitempty"`
+	Video                 *Video                `json:"video,omitempty"`
+	Voice                 *Voice                `json:"voice,omitempty"`
+	Caption               string                `json:"caption,omitempty"`
+	CaptionEntities       []MessageEntity       `json:"caption_entities,omitempty"`
+	ShowCaptionAboveMedia bool                  `json:"show_caption_above_media,omitempty"`
+	HasMediaSpoiler       bool                  `json:"has_media_spoiler,omitempty"`
+	Contact               *Contact              `json:"contact,omitempty"`
+	Location              *Location             `json:"location,omitempty"`
+	NewChatMembers        []User                `json:"new_chat_members,omitempty"`
+	LeftChatMember        *User                 `json:"left_chat_member,omitempty"`
+	NewChatTitle          string                `json:"new_chat_title,omitempty"`
+	GroupChatCreated      bool                  `json:"group_chat_created,omitempty"`
+	SupergroupChatCreated bool                  `json:"supergroup_chat_created,omitempty"`
+	ChannelChatCreated    bool                  `json:"channel_chat_created,omitempty"`
+	MigrateToChatID       int64                 `json:"migrate_to_chat_id,omitempty"`
+	MigrateFromChatID     int64                 `json:"migrate_from_chat_id,omitempty"`
+	ReplyMarkup           *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+// MaybeInaccessibleMessage is a *Message, or an *InaccessibleMessage when the
+// message was deleted or is otherwise unavailable to the bot.
+type MaybeInaccessibleMessage interface {
+	IsAccessible() bool
+	Message() *Message
+	InaccessibleMessage() *InaccessibleMessage
+	GetChat() Chat
+	GetMessageID() int
+	GetDate() int64
+
+	iMaybeInaccessibleMessage()
+}
+
+func (m *Message) IsAccessible() bool                        { return true }
+func (m *Message) Message() *Message                         { return m }
+func (m *Message) InaccessibleMessage() *InaccessibleMessage { return nil }
+func (m *Message) GetChat() Chat                             { return m.Chat }
+func (m *Message) GetMessageID() int                         { return m.MessageID }
+func (m *Message) GetDate() int64                            { return m.Date }
+func (m *Message) iMaybeInaccessibleMessage()                {}
+
+// InaccessibleMessage has a zero Date, which tells it apart from a Message.
+type InaccessibleMessage struct {
+	Chat      Chat  `json:"chat"`
+	MessageID int   `json:"message_id"`
+	Date      int64 `json:"date"`
+}
+
+func (m *InaccessibleMessage) IsAccessible() bool                        { return false }
+func (m *InaccessibleMessage) Message() *Message                         { return nil }
+func (m *InaccessibleMessage) InaccessibleMessage() *InaccessibleMessage { return m }
+func (m *InaccessibleMessage) GetChat() Chat                             { return m.Chat }
+func (m *InaccessibleMessage) GetMessageID() int                         { return m.MessageID }
+func (m *InaccessibleMessage) GetDate() int64                            { return m.Date }
+func (m *InaccessibleMessage) iMaybeInaccessibleMessage()                {}
+
+type TextQuote struct {
+	Text     string          `json:"text"`
+	Entities []MessageEntity `json:"entities,omitempty"`
+	Position int             `json:"position"`
+	IsManual bool            `json:"is_manual,omitempty"`
+}
+
+// ExternalReplyInfo is a message from another chat that a message replies to.
+type ExternalReplyInfo struct {
+	Origin             MessageOrigin       `json:"origin"`
+	Chat               *Chat               `json:"chat,omitempty"`
+	MessageID          int                 `json:"message_id,omitempty"`
+	LinkPreviewOptions *LinkPreviewOptions `json:"link_preview_options,omitempty"`
+	Animation          *Animation          `json:"animation,omitempty"`
+	Audio              *Audio              `json:"audio,omitempty"`
+	Document           *Document           `json:"document,omitempty"`
+	Photo              []PhotoSize         `json:"photo,omitempty"`
+	Sticker            *Sticker            `json:"sticker,omitempty"`
+	Video              *Video              `json:"video,omitempty"`
+	Voice              *Voice              `json:"voice,omitempty"`
+	HasMediaSpoiler    bool                `json:"has_media_spoiler,omitempty"`
+	Contact            *Contact            `json:"contact,omitempty"`
+	Location           *Location           `json:"location,omitempty"`
+}
+
+// UnmarshalJSON picks the origin type by its "type" field.
+func (e *ExternalReplyInfo) UnmarshalJSON(data []byte) error {
+	var probe struct {
+		Origin *struct {
+			Type string `json:"type"`
+		} `json:"origin"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return err
+	}
+	if probe.Origin == nil {
+		return errors.New("no origin")
+	}
+	type plain ExternalReplyInfo
+	var ue plain
+	switch probe.Origin.Type {
+	case OriginTypeUser:
+		ue.Origin = &MessageOriginUser{}
+	case OriginTypeHiddenUser:
+		ue.Origin = &MessageOriginHiddenUser{}
+	case OriginTypeChat:
+		ue.Origin = &MessageOriginChat{}
+	case OriginTypeChannel:
+		ue.Origin = &MessageOriginChannel{}
+	default:
+		return fmt.Errorf("unknown origin: %q", probe.Origin.Type)
+	}
+	if err := json.Unmarshal(data, &ue); err != nil {
+		return err
+	}
+	*e = ExternalReplyInfo(ue)
+	return nil
+}
+
+// MessageOrigin is one of MessageOriginUser, MessageOriginHiddenUser,
+// MessageOriginChat and MessageOriginChannel.
+type MessageOrigin interface {
+	OriginType() string
+	OriginalDate() int64
+
+	iMessageOrigin()
+}
+
+// Message origin types
+const (
+	OriginTypeUser       = "user"
+	OriginTypeHiddenUser = "hidden_user"
+	OriginTypeChat       = "chat"
+	OriginTypeChannel    = "channel"
+)
+
+type MessageOriginUser struct {
+	Type       string `json:"type"`
+	Date       int64  `json:"date"`
+	SenderUser User   `json:"sender_user"`
+}
+
+func (m *MessageOriginUser) OriginType() string  { return OriginTypeUser }
+func (m *MessageOriginUser) OriginalDate() int64 { return m.Date }
+func (m *MessageOriginUser) iMessageOrigin()     {}
+
+type MessageOriginHiddenUser struct {
+	Type           string `json:"type"`
+	Date           int64  `json:"date"`
+	SenderUserName string `json:"sender_user_name"`
+}
+
+func (m *MessageOriginHiddenUser) OriginType() string  { return OriginTypeHiddenUser }
+func (m *MessageOriginHiddenUser) OriginalDate() int64 { return m.Date }
+func (m *MessageOriginHiddenUser) iMessageOrigin()     {}
+
+type MessageOriginChat struct {
+	Type            string `json:"type"`
+	Date            int64  `json:"date"`
+	SenderChat      Chat   `json:"sender_chat"`
+	AuthorSignature string `json:"author_signature,omitempty"`
 }
 
+func (m *MessageOriginChat) OriginType() string  { return OriginTypeChat }
+func (m *MessageOriginChat) OriginalDate() int64 { return m.Date }
+func (m *MessageOriginChat) iMessageOrigin()     {}
+
+type MessageOriginChannel struct {
+	Type            string `json:"type"`
+	Date            int64  `json:"date"`
+	Chat            Chat   `json:"chat"`
+	MessageID       int    `json:"message_id"`
+	AuthorSignature string `json:"author_signature,omitempty"`
+}
+
+func (m *MessageOriginChannel) OriginType() string  { return OriginTypeChannel }
+func (m *MessageOriginChannel) OriginalDate() int64 { return m.Date }
+func (m *MessageOriginChannel) iMessageOrigin()     {}
+
+type PhotoSize struct {
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	FileSize     int    `json:"file_size,omitempty"`
+}
+
+type Animation struct {
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Duration     int    `json:"duration"`
+	FileName     string `json:"file_name,omitempty"`
+	MimeType     string `json:"mime_type,omitempty"`
+	FileSize     int64  `json:"file_size,omitempty"`
+}
+
+type Audio struct {
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	Duration     int    `json:"duration"`
+	Performer    string `json:"performer,omitempty"`
+	Title        string `json:"title,omitempty"`
+	FileName     string `json:"file_name,omitempty"`
+	MimeType     string `json:"mime_type,omitempty"`
+	FileSize     int64  `json:"file_size,omitempty"`
+}
+
+type Document struct {
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	FileName     string `json:"file_name,omitempty"`
+	MimeType     string `json:"mime_type,omitempty"`
+	FileSize     int64  `json:"file_size,omitempty"`
+}
+
+type Video struct {
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Duration     int    `json:"duration"`
+	FileName     string `json:"file_name,omitempty"`
+	MimeType     string `json:"mime_type,omitempty"`
+	FileSize     int64  `json:"file_size,omitempty"`
+}
+
+type Voice struct {
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	Duration     int    `json:"duration"`
+	MimeType     string `json:"mime_type,omitempty"`
+	FileSize     int64  `json:"file_size,omitempty"`
+}
+
+type Sticker struct {
+	FileID       string `json:"file_id"`
+	FileUniqueID string `json:"file_unique_id"`
+	Type         string `json:"type"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	IsAnimated   bool   `json:"is_animated"`
+	IsVideo      bool   `json:"is_video"`
+	Emoji        string `json:"emoji,omitempty"`
+	FileSize     int    `json:"file_size,omitempty"`
+}
+
+type Location struct {
+	Latitude           float64 `json:"latitude"`
+	Longitude          float64 `json:"longitude"`
+	HorizontalAccuracy float64 `json:"horizontal_accuracy,omitempty"`
+}
+
+type Contact struct {
+	PhoneNumber string `json:"phone_number"`
+	FirstName   string `json:"first_name"`
+	LastName    string `json:"last_name,omitempty"`
+	UserID      int64  `json:"user_id,omitempty"`
+	Vcard       string `json:"vcard,omitempty"`
+}
+
+// Chat types
+const (
+	ChatTypePrivate    = "private"
+	ChatTypeGroup      = "group"
+	ChatTypeSupergroup = "supergroup"
+	ChatTypeChannel    = "channel"
+)
+
 type Chat struct {
-	ID int64 `json:"id"`
+	ID               int64  `json:"id"`
+	Type             string `json:"type"`
+	Title            string `json:"title,omitempty"`
+	Username         string `json:"username,omitempty"`
+	FirstName        string `json:"first_name,omitempty"`
+	LastName         string `json:"last_name,omitempty"`
+	IsForum          bool   `json:"is_forum,omitempty"`
+	IsDirectMessages bool   `json:"is_direct_messages,omitempty"`
 }
 
 type User struct {
-	ID   int64  `json:"id"`
-	Name string `json:"name,omitempty"`
+	ID                        int64  `json:"id"`
+	IsBot                     bool   `json:"is_bot"`
+	FirstName                 string `json:"first_name"`
+	LastName                  string `json:"last_name,omitempty"`
+	Username                  string `json:"username,omitempty"`
+	LanguageCode              string `json:"language_code,omitempty"`
+	IsPremium                 bool   `json:"is_premium,omitempty"`
+	AddedToAttachmentMenu     bool   `json:"added_to_attachment_menu,omitempty"`
+	CanJoinGroups             bool   `json:"can_join_groups,omitempty"`
+	CanReadAllGroupMessages   bool   `json:"can_read_all_group_messages,omitempty"`
+	SupportsInlineQueries     bool   `json:"supports_inline_queries,omitempty"`
+	CanConnectToBusiness      bool   `json:"can_connect_to_business,omitempty"`
+	HasMainWebApp             bool   `json:"has_main_web_app,omitempty"`
+	HasTopicsEnabled          bool   `json:"has_topics_enabled,omitempty"`
+	AllowsUsersToCreateTopics bool   `json:"allows_users_to_create_topics,omitempty"`
+	CanManageBots             bool   `json:"can_manage_bots,omitempty"`
 }
 
 type MessageEntity struct {
@@ -52,8 +488,143 @@ type MessageEntity struct {
 }
 
 type CallbackQuery struct {
-	ID      string   `json:"id"`
-	From    *User    `json:"from"`
-	Message *Message `json:"message,omitempty"`
-	Data    string   `json:"data"`
+	ID              string                   `json:"id"`
+	From            User                     `json:"from"`
+	Message         MaybeInaccessibleMessage `json:"message,omitempty"`
+	InlineMessageID string                   `json:"inline_message_id,omitempty"`
+	ChatInstance    string                   `json:"chat_instance"`
+	Data            string                   `json:"data,omitempty"`
+	GameShortName   string                   `json:"game_short_name,omitempty"`
+}
+
+// UnmarshalJSON picks the message type by its date, like telego does.
+func (q *CallbackQuery) UnmarshalJSON(data []byte) error {
+	var probe struct {
+		Message *struct {
+			Date int64 `json:"date"`
+		} `json:"message"`
+	}
+	if err := json.Unmarshal(data, &probe); err != nil {
+		return err
+	}
+	type plain CallbackQuery
+	var uq plain
+	if probe.Message != nil {
+		if probe.Message.Date == 0 {
+			uq.Message = &InaccessibleMessage{}
+		} else {
+			uq.Message = &Message{}
+		}
+	}
+	if err := json.Unmarshal(data, &uq); err != nil {
+		return err
+	}
+	*q = CallbackQuery(uq)
+	return nil
+}
+
+// ChatMember is one of ChatMemberOwner, ChatMemberAdministrator,
+// ChatMemberMember, ChatMemberLeft and ChatMemberBanned. Telemock doesn't
+// simulate restricted members.
+type ChatMember interface {
+	MemberStatus() string
+	MemberUser() User
+	MemberIsMember() bool
+
+	iChatMember()
+}
+
+// ChatMember statuses
+const (
+	MemberStatusCreator       = "creator"
+	MemberStatusAdministrator = "administrator"
+	MemberStatusMember        = "member"
+	MemberStatusRestricted    = "restricted"
+	MemberStatusLeft          = "left"
+	MemberStatusBanned        = "kicked"
+)
+
+type ChatMemberOwner struct {
+	Status      string `json:"status"`
+	User        User   `json:"user"`
+	IsAnonymous bool   `json:"is_anonymous"`
+	CustomTitle string `json:"custom_title,omitempty"`
+}
+
+func (m *ChatMemberOwner) MemberStatus() string { return MemberStatusCreator }
+func (m *ChatMemberOwner) MemberUser() User     { return m.User }
+func (m *ChatMemberOwner) MemberIsMember() bool { return true }
+func (m *ChatMemberOwner) iChatMember()         {}
+
+type ChatMemberAdministrator struct {
+	Status                  string `json:"status"`
+	User                    User   `json:"user"`
+	CanBeEdited             bool   `json:"can_be_edited"`
+	IsAnonymous             bool   `json:"is_anonymous"`
+	CanManageChat           bool   `json:"can_manage_chat"`
+	CanDeleteMessages       bool   `json:"can_delete_messages"`
+	CanManageVideoChats     bool   `json:"can_manage_video_chats"`
+	CanRestrictMembers      bool   `json:"can_restrict_members"`
+	CanPromoteMembers       bool   `json:"can_promote_members"`
+	CanChangeInfo           bool   `json:"can_change_info"`
+	CanInviteUsers          bool   `json:"can_invite_users"`
+	CanPostStories          bool   `json:"can_post_stories"`
+	CanEditStories          bool   `json:"can_edit_stories"`
+	CanDeleteStories        bool   `json:"can_delete_stories"`
+	CanPostMessages         bool   `json:"can_post_messages,omitempty"`
+	CanEditMessages         bool   `json:"can_edit_messages,omitempty"`
+	CanPinMessages          bool   `json:"can_pin_messages,omitempty"`
+	CanManageTopics         bool   `json:"can_manage_topics,omitempty"`
+	CanManageDirectMessages bool   `json:"can_manage_direct_messages,omitempty"`
+	CustomTitle             string `json:"custom_title,omitempty"`
+}
+
+func (m *ChatMemberAdministrator) MemberStatus() string { return MemberStatusAdministrator }
+func (m *ChatMemberAdministrator) MemberUser() User     { return m.User }
+func (m *ChatMemberAdministrator) MemberIsMember() bool { return true }
+func (m *ChatMemberAdministrator) iChatMember()         {}
+
+type ChatMemberMember struct {
+	Status    string `json:"status"`
+	User      User   `json:"user"`
+	UntilDate int64  `json:"until_date,omitempty"`
+}
+
+func (m *ChatMemberMember) MemberStatus() string { return MemberStatusMember }
+func (m *ChatMemberMember) MemberUser() User     { return m.User }
+func (m *ChatMemberMember) MemberIsMember() bool { return true }
+func (m *ChatMemberMember) iChatMember()         {}
+
+type ChatMemberLeft struct {
+	Status string `json:"status"`
+	User   User   `json:"user"`
+}
+
+func (m *ChatMemberLeft) MemberStatus() string { return MemberStatusLeft }
+func (m *ChatMemberLeft) MemberUser() User     { return m.User }
+func (m *ChatMemberLeft) MemberIsMember() bool { return false }
+func (m *ChatMemberLeft) iChatMember()         {}
+
+type ChatMemberBanned struct {
+	Status    string `json:"status"`
+	User      User   `json:"user"`
+	UntilDate int64  `json:"until_date"`
+}
+
+func (m *ChatMemberBanned) MemberStatus() string { return MemberStatusBanned }
+func (m *ChatMemberBanned) MemberUser() User     { return m.User }
+func (m *ChatMemberBanned) MemberIsMember() bool { return false }
+func (m *ChatMemberBanned) iChatMember()         {}
+
+type GetChatMemberParams struct {
+	ChatID ChatID `json:"chat_id"`
+	UserID int64  `json:"user_id"`
+}
+
+type GetChatAdministratorsParams struct {
+	ChatID ChatID `json:"chat_id"`
+}
+
+type GetChatMemberCountParams struct {
+	ChatID ChatID `json:"chat_id"`
 }
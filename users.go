@@ -0,0 +1,74 @@
+package telemock
+
+import "errors"
+
+// SetUserProfile registers the profile of a simulated user: the name,
+// username, language and premium status the bot sees in Message.From and
+// CallbackQuery.From of everything the user sends, in the private chat with
+// the user and in every group. Registering a profile again replaces it.
+func (b *Bot) SetUserProfile(user User) error {
+	switch {
+	case user.ID <= 0:
+		return errors.New("telemock: user ID must be positive")
+	case user.ID == b.me.ID:
+		return errors.New("telemock: can't change the bot's profile")
+	case user.FirstName == "":
+		return errors.New("telemock: user first name is empty")
+	}
+	changed := b.store.setUser(user, func(m ChatMember) ChatMember { return withUser(m, user) })
+	for _, chatID := range changed {
+		if err := b.announceChat(chatID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UserProfile returns the profile registered with SetUserProfile.
+func (b *Bot) UserProfile(userID int64) (User, bool) {
+	return b.store.user(userID)
+}
+
+// user returns the registered profile of a user, or a user with nothing but
+// the ID
+func (b *Bot) user(id int64) User {
+	if u, ok := b.store.user(id); ok {
+		return u
+	}
+	return User{ID: id}
+}
+
+// profile replaces user with the registered profile of the same ID, if any
+func (b *Bot) profile(user User) User {
+	if u, ok := b.store.user(user.ID); ok {
+		return u
+	}
+	return user
+}
+
+// withUser returns a copy of m with user in it
+func withUser(m ChatMember, user User) ChatMember {
+	switch m := m.(type) {
+	case *ChatMemberOwner:
+		c := *m
+		c.User = user
+		return &c
+	case *ChatMemberAdministrator:
+		c := *m
+		c.User = user
+		return &c
+	case *ChatMemberMember:
+		c := *m
+		c.User = user
+		return &c
+	case *ChatMemberLeft:
+		c := *m
+		c.User = user
+		return &c
+	case *ChatMemberBanned:
+		c := *m
+		c.User = user
+		return &c
+	}
+	return m
+}
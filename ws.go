@@ -3,31 +3,40 @@ package telemock
 import (
 	"encoding/json"
 	"errors"
-	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
-	"sync/atomic"
-	"time"
 
-	"github.com/gorilla/websocket"
 	util "github.com/teterevlev/telemock-go/internal/util"
 )
 
 type clientPayload struct {
-	ChatID       interface{} `json:"chat_id"`
-	Text         string      `json:"text,omitempty"`
-	MessageID    interface{} `json:"message_id,omitempty"`
-	CallbackData string      `json:"callback_data,omitempty"`
+	ChatID       interface{}   `json:"chat_id"`
+	Text         string        `json:"text,omitempty"`
+	MessageID    interface{}   `json:"message_id,omitempty"`
+	CallbackData string        `json:"callback_data,omitempty"`
+	Button       string        `json:"button,omitempty"`  // text of an inline button to press
+	FromID       interface{}   `json:"from_id,omitempty"` // sender in groups and channels
+	User         *User         `json:"user,omitempty"`    // a profile to register
+	ReplyTo      interface{}   `json:"reply_to_message_id,omitempty"`
+	Quote        string        `json:"quote,omitempty"` // part of the replied message
+	Subscribe    []interface{} `json:"subscribe,omitempty"`
+	Unsubscribe  []interface{} `json:"unsubscribe,omitempty"`
 }
 
 type outboundPayload struct {
-	ChatID           interface{}           `json:"chat_id"`
-	Text             string                `json:"text"`
-	From             string                `json:"from"`
-	ReplyToMessageID interface{}           `json:"reply_to_message_id,omitempty"`
-	IsReply          bool                  `json:"is_reply,omitempty"`
-	MessageID        interface{}           `json:"message_id"`
-	ReplyMarkup      *InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+	ChatID           interface{} `json:"chat_id"`
+	Text             string      `json:"text"`
+	From             string      `json:"from"`
+	ReplyToMessageID interface{} `json:"reply_to_message_id,omitempty"`
+	IsReply          bool        `json:"is_reply,omitempty"`
+	MessageID        interface{} `json:"message_id"`
+	ReplyMarkup      interface{} `json:"reply_markup,omitempty"` // a ReplyMarkup
+	Event            string      `json:"event,omitempty"`        // empty for new messages
+	MessageIDs       []int       `json:"message_ids,omitempty"`
+	Caption          string      `json:"caption,omitempty"`
+	Media            string      `json:"media,omitempty"` // photo, document, ...
+	FileURL          string      `json:"file_url,omitempty"`
 }
 
 func (b *Bot) handleWS(w http.ResponseWriter, r *http.Request) {
@@ -36,28 +45,98 @@ func (b *Bot) handleWS(w http.ResponseWriter, r *http.Request) {
 		b.logger.Printf("telemock: upgrade failed: %v\n", err)
 		return
 	}
-	b.addClient(conn)
+	c := newClient(conn, b.clientQ)
+	applyRouting(c, r)
+	if r.URL.Query().Get("v") == "1" {
+		c.setProtocol(1)
+	}
+	// живые события ждут, пока клиент не получит историю
+	c.holding = true
+	if !b.addClient(c) {
+		_ = conn.Close()
+		return
+	}
 	b.logger.Printf("telemock: client connected %s\n", conn.RemoteAddr())
-	go b.readLoop(conn)
+	go b.writeLoop(c)
+	b.replayHistory(c)
+	b.releaseHeld(c)
+	go b.readLoop(c)
 }
 
-func (b *Bot) addClient(conn *websocket.Conn) {
+// addClient registers a connection; it reports false once the bot is closing
+func (b *Bot) addClient(c *client) bool {
 	b.mu.Lock()
-	b.clients[conn] = struct{}{}
-	b.mu.Unlock()
+	defer b.mu.Unlock()
+	if b.isClosed() {
+		return false
+	}
+	b.clients[c] = struct{}{}
+	b.readers.Add(1)
+	return true
+}
+
+// releaseHeld sends the live frames held during the history replay, then
+// lets new ones through
+func (b *Bot) releaseHeld(c *client) {
+	for {
+		held := c.takeHeld()
+		if len(held) == 0 {
+			return
+		}
+		for _, data := range held {
+			if !c.enqueue(data, b.slowPolicy) {
+				b.removeClient(c)
+				return
+			}
+		}
+	}
 }
 
-func (b *Bot) removeClient(conn *websocket.Conn) {
+// applyRouting reads subscriptions from the query string: chat_id (repeated or
+// comma-separated) limits the client to those chats, observer=1 shows everything.
+// Without either the client observes all chats, as legacy clients expect.
+func applyRouting(c *client, r *http.Request) {
+	q := r.URL.Query()
+	var ids []int64
+	for _, v := range q["chat_id"] {
+		for _, part := range strings.Split(v, ",") {
+			if id, err := util.ParseChatID(strings.TrimSpace(part)); err == nil {
+				ids = append(ids, id)
+			}
+		}
+	}
+	if len(ids) > 0 {
+		c.subscribe(ids...)
+	}
+	if obs := q.Get("observer"); obs == "1" || obs == "true" {
+		c.setObserver()
+	}
+}
+
+// parseChatIDs converts loosely typed chat IDs, skipping invalid ones
+func parseChatIDs(vs []interface{}) []int64 {
+	ids := make([]int64, 0, len(vs))
+	for _, v := range vs {
+		if id, err := util.ParseChatID(v); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+func (b *Bot) removeClient(c *client) {
 	b.mu.Lock()
-	delete(b.clients, conn)
+	delete(b.clients, c)
 	b.mu.Unlock()
-	_ = conn.Close()
+	c.stop()
 }
 
-func (b *Bot) readLoop(conn *websocket.Conn) {
+func (b *Bot) readLoop(c *client) {
+	conn := c.conn
 	defer func() {
-		b.removeClient(conn)
+		b.removeClient(c)
 		b.logger.Printf("telemock: client disconnected %s\n", conn.RemoteAddr())
+		b.readers.Done()
 	}()
 	for {
 		_, raw, err := conn.ReadMessage()
@@ -66,72 +145,373 @@ func (b *Bot) readLoop(conn *websocket.Conn) {
 		}
 		// log incoming payload
 		b.logRequest(conn.RemoteAddr().String(), raw)
-		var cp clientPayload
-		if err := json.Unmarshal(raw, &cp); err != nil {
+		var env envelope
+		if err := json.Unmarshal(raw, &env); err != nil {
 			b.logger.Printf("telemock: invalid payload: %v\n", err)
+			if c.protocol() >= 1 {
+				b.sendError(c, nil, 400, "Bad Request: can't parse frame: "+err.Error())
+			}
 			continue
 		}
-		if cp.CallbackData != "" {
-			chatID, _ := util.ParseChatID(cp.ChatID)
-			msgID := util.ParseToInt64(cp.MessageID)
-			msg := &Message{
-				MessageID: msgID,
-				Chat:      Chat{ID: chatID},
-				Text:      cp.Text,
-				From:      &User{ID: chatID},
-			}
-			cq := &CallbackQuery{
-				ID:      fmt.Sprintf("cb-%d-%d", time.Now().UnixNano(), msgID),
-				From:    &User{ID: chatID},
-				Message: msg,
-				Data:    cp.CallbackData,
-			}
-			upd := Update{
-				UpdateID:      atomic.AddInt64(&b.nextUpdID, 1),
-				CallbackQuery: cq,
-			}
-			select {
-			case b.updates <- upd:
-			default:
-				_ = b.drainOneUpdate()
-				b.updates <- upd
-			}
-		} else if cp.Text != "" {
-			chatID, _ := util.ParseChatID(cp.ChatID)
-			msgID := util.ParseToInt64(cp.MessageID)
-			if msgID == 0 {
-				msgID = atomic.AddInt64(&b.nextMsgID, 1)
-			}
-			msg := &Message{
-				MessageID: msgID,
-				Chat:      Chat{ID: chatID},
-				Text:      cp.Text,
-				From:      &User{ID: chatID},
-			}
-			// Если начинается с /команда, добавим Entity типа bot_command до первого пробела
-			if len(cp.Text) > 0 && cp.Text[0] == '/' {
-				end := len(cp.Text)
-				if sp := indexOfSpace(cp.Text); sp != -1 {
-					end = sp
-				}
-				if end > 1 {
-					msg.Entities = append(msg.Entities, MessageEntity{Type: "bot_command", Offset: 0, Length: end})
-				}
-			}
-			upd := Update{
-				UpdateID: atomic.AddInt64(&b.nextUpdID, 1),
-				Message:  msg,
-			}
-			select {
-			case b.updates <- upd:
-			default:
-				_ = b.drainOneUpdate()
-				b.updates <- upd
-			}
+		keepReading := true
+		if env.Type != "" {
+			keepReading = b.handleEnvelope(c, env)
 		} else {
+			keepReading = b.handleLegacy(c, raw)
+		}
+		if !keepReading {
+			return
+		}
+	}
+}
+
+// handleLegacy processes a v0 frame: a callback if callback_data is set,
+// otherwise a text message. Anything else is ignored.
+func (b *Bot) handleLegacy(c *client, raw []byte) bool {
+	var cp clientPayload
+	if err := json.Unmarshal(raw, &cp); err != nil {
+		b.logger.Printf("telemock: invalid payload: %v\n", err)
+		return true
+	}
+	if cp.Subscribe != nil {
+		c.subscribe(parseChatIDs(cp.Subscribe)...)
+	}
+	if cp.Unsubscribe != nil {
+		c.unsubscribe(parseChatIDs(cp.Unsubscribe)...)
+	}
+	if cp.User != nil {
+		if err := b.SetUserProfile(*cp.User); err != nil {
+			b.sendAPIError(c, nil, err)
+			return true
+		}
+	}
+	if cp.CallbackData == "" && cp.Text == "" && cp.Button == "" {
+		return true
+	}
+	chatID, _ := util.ParseChatID(cp.ChatID)
+	c.follow(chatID)
+	msgID := int(util.ParseToInt64(cp.MessageID))
+	fromID := util.ParseToInt64(cp.FromID)
+	if cp.Button != "" {
+		return b.pressLegacy(c, chatID, fromID, msgID, cp.Button)
+	}
+
+	var upd Update
+	var err error
+	if cp.CallbackData != "" {
+		upd, err = b.callbackUpdate(chatID, fromID, msgID, cp.Text, cp.CallbackData)
+	} else {
+		upd, err = b.textUpdate(chatID, fromID, msgID, cp.Text)
+		if replyTo := int(util.ParseToInt64(cp.ReplyTo)); err == nil && replyTo != 0 {
+			err = b.attachReply(upd.message(), &ReplyParameters{MessageID: replyTo, Quote: cp.Quote})
+		}
+	}
+	if err != nil {
+		b.sendAPIError(c, nil, err)
+		return true
+	}
+	return !errors.Is(b.deliverFromClient(c, nil, upd), ErrBotClosed)
+}
+
+// pressFrameV0 tells a v0 client what pressing a button did
+type pressFrameV0 struct {
+	Type            string `json:"type"`
+	ChatID          int64  `json:"chat_id"`
+	MessageID       int    `json:"message_id"`
+	Action          string `json:"action"`
+	URL             string `json:"url,omitempty"`
+	Query           string `json:"query,omitempty"`
+	CopyText        string `json:"copy_text,omitempty"`
+	CallbackQueryID string `json:"callback_query_id,omitempty"`
+}
+
+// pressLegacy presses an inline button for a v0 client and answers with a
+// "press" frame
+func (b *Bot) pressLegacy(c *client, chatID, fromID int64, msgID int, text string) bool {
+	res, err := b.pressButton(chatID, fromID, msgID, text)
+	if err != nil {
+		b.sendAPIError(c, nil, err)
+		return true
+	}
+	frame := pressFrameV0{Type: "press", ChatID: chatID, MessageID: msgID,
+		Action: res.Action, URL: res.URL, Query: res.Query, CopyText: res.CopyText}
+	if res.Update == nil {
+		b.sendFrame(c, frame)
+		return true
+	}
+	// кадр уходит до апдейта, чтобы ответ бота пришёл после него
+	frame.CallbackQueryID = res.Update.CallbackQuery.ID
+	b.sendFrame(c, frame)
+	return !errors.Is(b.deliverFromClient(c, nil, *res.Update), ErrBotClosed)
+}
+
+// textUpdate builds a message update from a client writing to chatID as user
+// fromID, see sender. A zero msgID is assigned from the bot's sequence; a
+// given one must not be used in the chat yet.
+func (b *Bot) textUpdate(chatID, fromID int64, msgID int, text string) (Update, error) {
+	chat, err := b.chat(chatID)
+	if err != nil {
+		return Update{}, err
+	}
+	from, err := b.sender(chat, fromID, true)
+	if err != nil {
+		return Update{}, err
+	}
+	if msgID == 0 {
+		msgID = b.freeMessageID(chatID)
+	} else if _, ok := b.store.get(chatID, msgID); ok {
+		return Update{}, errBadRequest("message_id is already used")
+	}
+	return b.userMessage(chat, from, msgID, text), nil
+}
+
+// editUpdate builds an edit of message msgID by user fromID, who must have
+// written it
+func (b *Bot) editUpdate(chatID, fromID int64, msgID int, text string) (Update, error) {
+	chat, err := b.chat(chatID)
+	if err != nil {
+		return Update{}, err
+	}
+	from, err := b.sender(chat, fromID, true)
+	if err != nil {
+		return Update{}, err
+	}
+	orig, ok := b.store.get(chatID, msgID)
+	if !ok {
+		return Update{}, errBadRequest("message to edit not found")
+	}
+	// посты канала правит любой администратор, остальное — только автор
+	own := chat.Type == ChatTypeChannel || orig.From != nil && orig.From.ID == from.ID
+	if !own || b.store.sentByBot(chatID, msgID) {
+		return Update{}, errBadRequest("message can't be edited")
+	}
+	upd := b.userMessage(chat, from, msgID, text)
+	msg := upd.message()
+	msg.Date, msg.EditDate = orig.Date, msg.Date
+	msg.ReplyToMessage, msg.ExternalReply, msg.Quote = orig.ReplyToMessage, orig.ExternalReply, orig.Quote
+	upd.asEdit()
+	return upd, nil
+}
+
+// userMessage builds the update of a text message from a user
+func (b *Bot) userMessage(chat Chat, from User, msgID int, text string) Update {
+	msg := &Message{
+		MessageID: msgID,
+		Date:      b.now().Unix(),
+		Chat:      chat,
+		Text:      text,
+	}
+	// Если начинается с /команда, добавим Entity типа bot_command до первого пробела
+	if len(text) > 0 && text[0] == '/' {
+		end := len(text)
+		if sp := indexOfSpace(text); sp != -1 {
+			end = sp
+		}
+		if end > 1 {
+			msg.Entities = append(msg.Entities, MessageEntity{Type: "bot_command", Offset: 0, Length: end})
+		}
+	}
+	upd := Update{UpdateID: b.nextUpdateID()}
+	if chat.Type == ChatTypeChannel {
+		// пост канала идёт от имени канала
+		msg.SenderChat = &chat
+		upd.ChannelPost = msg
+	} else {
+		msg.From = &from
+		upd.Message = msg
+	}
+	return upd
+}
+
+// message returns the message an update carries, if any
+func (u Update) message() *Message {
+	switch {
+	case u.Message != nil:
+		return u.Message
+	case u.EditedMessage != nil:
+		return u.EditedMessage
+	case u.ChannelPost != nil:
+		return u.ChannelPost
+	}
+	return u.EditedChannelPost
+}
+
+// asEdit turns a new message into an edit of the same message
+func (u *Update) asEdit() {
+	u.EditedMessage, u.Message = u.Message, nil
+	u.EditedChannelPost, u.ChannelPost = u.ChannelPost, nil
+}
+
+// callbackUpdate builds a callback query for a button under message msgID,
+// pressed by user fromID
+func (b *Bot) callbackUpdate(chatID, fromID int64, msgID int, text, data string) (Update, error) {
+	chat, err := b.chat(chatID)
+	if err != nil {
+		return Update{}, err
+	}
+	from, err := b.sender(chat, fromID, false)
+	if err != nil {
+		return Update{}, err
+	}
+	var msg MaybeInaccessibleMessage = &InaccessibleMessage{Chat: chat, MessageID: msgID}
+	switch stored, ok := b.store.get(chatID, msgID); {
+	case ok:
+		// кнопка висит под сообщением бота: отдаём его актуальную (возможно отредактированную) версию
+		msg = &stored
+	case text != "":
+		// v0-клиент прислал текст сообщения сам
+		msg = &Message{MessageID: msgID, Date: b.now().Unix(), Chat: chat, Text: text}
+	}
+	cq := &CallbackQuery{
+		ID:           b.nextCallbackID(),
+		From:         from,
+		Message:      msg,
+		ChatInstance: strconv.FormatInt(chatID, 10),
+		Data:         data,
+	}
+	return Update{
+		UpdateID:      b.nextUpdateID(),
+		CallbackQuery: cq,
+	}, nil
+}
+
+// errorFrame reports a rejected client payload to a v0 client
+type errorFrame struct {
+	Type        string `json:"type"`
+	ErrorCode   int    `json:"error_code"`
+	Description string `json:"description"`
+}
+
+// deliverFromClient enqueues an update read from c. An update the overflow
+// policy rejected or dropped is reported to the client as an error frame
+// answering frame id, instead of an ack.
+func (b *Bot) deliverFromClient(c *client, id json.RawMessage, upd Update) error {
+	if upd.CallbackQuery != nil {
+		// регистрируем до отправки: бот может ответить раньше, чем вернётся enqueue
+		b.callbacks.add(upd.CallbackQuery, c, b.now(), b.cbTimeout)
+	}
+	err := b.enqueueUpdate(upd)
+	if errors.Is(err, ErrUpdatesFull) || errors.Is(err, errUpdateDropped) {
+		b.sendError(c, id, 429, "Too Many Requests: updates buffer is full")
+	}
+	return err
+}
+
+// sendError queues an error frame for a single client, in its protocol version
+func (b *Bot) sendError(c *client, id json.RawMessage, code int, description string) {
+	var frame any = errorFrame{Type: "error", ErrorCode: code, Description: description}
+	if c.protocol() >= 1 {
+		frame = newEnvelope("error", id, errorPayload{ErrorCode: code, Description: description})
+	}
+	b.sendFrame(c, frame)
+}
+
+// sendAPIError reports a rejected frame, as a Bad Request unless err is an *Error
+func (b *Bot) sendAPIError(c *client, id json.RawMessage, err error) {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		apiErr = errBadRequest(err.Error())
+	}
+	b.sendError(c, id, apiErr.ErrorCode, apiErr.Description)
+}
+
+// sendFrame queues a single frame for c
+func (b *Bot) sendFrame(c *client, frame any) {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return
+	}
+	if !c.enqueue(data, b.slowPolicy) {
+		b.removeClient(c)
+	}
+}
+
+// anyMarkup keeps a nil markup nil once stored in an interface{}
+func anyMarkup(markup ReplyMarkup) interface{} {
+	if markup == nil {
+		return nil
+	}
+	return markup
+}
+
+// legacyPayload renders a bot event in the v0 format
+func legacyPayload(ev Event) outboundPayload {
+	if ev.Deleted != nil {
+		return outboundPayload{
+			ChatID:     ev.Deleted.ChatID,
+			From:       "bot",
+			Event:      string(ev.Type),
+			MessageIDs: ev.Deleted.MessageIDs,
+		}
+	}
+	msg := ev.Message
+	out := outboundPayload{
+		ChatID:      msg.Chat.ID,
+		Text:        msg.Text,
+		From:        "bot",
+		MessageID:   msg.MessageID,
+		ReplyMarkup: anyMarkup(ev.markup()),
+		Caption:     msg.Caption,
+		FileURL:     ev.FileURL,
+	}
+	out.Media, _ = mediaOf(msg)
+	if msg.ReplyToMessage != nil {
+		out.ReplyToMessageID = msg.ReplyToMessage.MessageID
+		out.IsReply = true
+	}
+	if ev.Type != EventMessage {
+		out.Event = string(ev.Type)
+	}
+	return out
+}
+
+// broadcastWS writes a bot event to every client routed to its chat, encoded
+// in each client's protocol version
+func (b *Bot) broadcastWS(ev Event) error {
+	chatID := ev.chatID()
+
+	b.mu.RLock()
+	conns := make([]*client, 0, len(b.clients))
+	for c := range b.clients {
+		if c.wants(chatID) {
+			conns = append(conns, c)
+		}
+	}
+	b.mu.RUnlock()
+
+	if len(conns) == 0 {
+		return nil
+	}
+
+	dataV1, err := json.Marshal(newEnvelope(string(ev.Type), nil, ev.payload()))
+	if err != nil {
+		return err
+	}
+	// в v0 нет кадра для участников чата
+	var data []byte
+	if ev.Members == nil {
+		if data, err = json.Marshal(legacyPayload(ev)); err != nil {
+			return err
+		}
+		b.logLine(data)
+	} else {
+		b.logLine(dataV1)
+	}
+
+	for _, c := range conns {
+		frame := data
+		if c.protocol() >= 1 {
+			frame = dataV1
+		}
+		if frame == nil || c.hold(frame) {
 			continue
 		}
+		if !c.enqueue(frame, b.slowPolicy) {
+			b.logger.Printf("telemock: disconnecting slow client %s\n", c.conn.RemoteAddr())
+			b.removeClient(c)
+		}
 	}
+	return nil
 }
 
 // indexOfSpace returns index of first space or -1
@@ -139,11 +519,12 @@ func indexOfSpace(s string) int {
 	return strings.IndexByte(s, ' ')
 }
 
-func (b *Bot) drainOneUpdate() error {
+// drainOneUpdate takes the oldest update out of the queue, if there is one
+func (b *Bot) drainOneUpdate() (Update, bool) {
 	select {
-	case <-b.updates:
-		return nil
+	case upd := <-b.updates:
+		return upd, true
 	default:
-		return errors.New("updates empty")
+		return Update{}, false
 	}
 }